@@ -2,6 +2,7 @@ package api
 
 import (
 	"back/database"
+	"back/internal/library"
 	"database/sql"
 	"net/http"
 
@@ -12,7 +13,13 @@ func AccessHandler(bookDB *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.DefaultQuery("path", "")
 
-		database.UpdateBookLastOpened(bookDB, "/books"+path)
+		filePath, _, err := library.ToFS(path)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
+		database.UpdateBookLastOpened(bookDB, filePath)
 
 		c.JSON(http.StatusOK, "")
 	}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"back/database"
+	"back/internal/library"
 	"database/sql"
 	"net/http"
 	"strconv"
@@ -38,9 +39,15 @@ func AllHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 			order = "ASC"
 		}
 
+		pathPrefix, ok := libraryPrefix(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown library"})
+			return
+		}
+
 		offset := (page - 1) * pageSize
 
-		booksData, err := database.GetBooksFlat(bookDB, sortColumn, order, pageSize+1, offset)
+		booksData, err := database.GetBooksFlat(bookDB, pathPrefix, sortColumn, order, pageSize+1, offset)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
 			return
@@ -56,7 +63,8 @@ func AllHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 		for i, b := range booksData {
 			books[i] = BookEntry{
 				Type:            b.Type,
-				Path:            strings.TrimPrefix(b.Path, "/books"),
+				Library:         libraryName(b.Path),
+				Path:            library.ToAPI(b.Path),
 				Cover:           strings.TrimPrefix(b.CoverPath, "/cache/covers"),
 				Title:           b.Title,
 				CurrentPosition: b.CurrentPosition,
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/library"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthorEntry struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	SortName string `json:"sortName"`
+	Books    int    `json:"books"`
+}
+
+func AuthorsHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sortBy := c.DefaultQuery("sort", "name")
+		order := c.DefaultQuery("order", "asc")
+		pageStr := c.DefaultQuery("page", "1")
+		role := c.DefaultQuery("role", "")
+
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		validSorts := map[string]string{
+			"name":        "a.sort_name COLLATE NOCASE",
+			"books":       "COUNT(DISTINCT b.path)",
+			"added_time":  "MAX(b.added_time)",
+			"last_opened": "MAX(b.last_opened)",
+		}
+
+		sortColumn, ok := validSorts[sortBy]
+		if !ok {
+			sortColumn = "a.sort_name COLLATE NOCASE"
+		}
+
+		order = strings.ToUpper(order)
+		if order != "ASC" && order != "DESC" {
+			order = "ASC"
+		}
+
+		pathPrefix, ok := libraryPrefix(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown library"})
+			return
+		}
+
+		offset := (page - 1) * pageSize
+
+		authorsData, err := database.GetAuthors(bookDB, pathPrefix, role, sortColumn, order, pageSize+1, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		hasMore := false
+		if len(authorsData) > pageSize {
+			hasMore = true
+			authorsData = authorsData[:pageSize]
+		}
+
+		authors := make([]AuthorEntry, len(authorsData))
+		for i, a := range authorsData {
+			authors[i] = AuthorEntry{
+				ID:       a.ID,
+				Name:     a.Name,
+				SortName: a.SortName,
+				Books:    a.Books,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"authors": authors,
+			"hasMore": hasMore,
+		})
+	}
+}
+
+func AuthorBooksHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+
+		sortBy := c.DefaultQuery("sort", "title")
+		order := c.DefaultQuery("order", "asc")
+		pageStr := c.DefaultQuery("page", "1")
+
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		validSorts := map[string]string{
+			"title":       "title",
+			"added_time":  "added_time",
+			"last_opened": "last_opened",
+			"progress":    "progress",
+		}
+
+		sortColumn, ok := validSorts[sortBy]
+		if !ok {
+			sortColumn = "title"
+		}
+
+		order = strings.ToUpper(order)
+		if order != "ASC" && order != "DESC" {
+			order = "ASC"
+		}
+
+		pathPrefix, ok := libraryPrefix(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown library"})
+			return
+		}
+
+		author, err := database.GetAuthorByID(bookDB, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			}
+			return
+		}
+
+		offset := (page - 1) * pageSize
+
+		booksData, err := database.GetBooksByAuthor(bookDB, id, pathPrefix, sortColumn, order, pageSize+1, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		hasMore := false
+		if len(booksData) > pageSize {
+			hasMore = true
+			booksData = booksData[:pageSize]
+		}
+
+		books := make([]BookEntry, len(booksData))
+		for i, b := range booksData {
+			books[i] = BookEntry{
+				Type:            b.Type,
+				Library:         libraryName(b.Path),
+				Path:            library.ToAPI(b.Path),
+				Cover:           strings.TrimPrefix(b.CoverPath, "/cache/covers"),
+				Title:           b.Title,
+				CurrentPosition: b.CurrentPosition,
+				Progress:        b.Progress,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"id":       author.ID,
+			"name":     author.Name,
+			"sortName": author.SortName,
+			"books":    books,
+			"hasMore":  hasMore,
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/library"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BookDetail struct {
+	Type         string                 `json:"type"`
+	Library      string                 `json:"library"`
+	Path         string                 `json:"path"`
+	Cover        string                 `json:"cover"`
+	Title        string                 `json:"title"`
+	Contributors []database.Contributor `json:"contributors"`
+	Series       string                 `json:"series"`
+	SeriesNumber string                 `json:"seriesNumber"`
+	Publisher    string                 `json:"publisher"`
+	Language     string                 `json:"language"`
+	Direction    string                 `json:"direction"`
+	Identifiers  []database.Identifier  `json:"identifiers"`
+	Description  string                 `json:"description"`
+	Published    string                 `json:"published"`
+	Pages        int                    `json:"pages"`
+	Rating       float64                `json:"rating"`
+	// Edited lists the fields changed through PUT /api/book/metadata.
+	Edited          []string `json:"edited"`
+	Size            int64    `json:"size"`
+	AddedTime       int64    `json:"addedTime"`
+	LastModded      int64    `json:"lastModded"`
+	LastOpened      int64    `json:"lastOpened"`
+	CurrentPosition string   `json:"currentPosition"`
+	Progress        float64  `json:"progress"`
+}
+
+func BookHandler(bookDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.DefaultQuery("path", "")
+
+		filePath, _, err := library.ToFS(path)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
+		detail, err := bookDetail(bookDB, filePath)
+		if err != nil {
+			if errors.Is(err, errBookNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, detail)
+	}
+}
+
+var errBookNotFound = errors.New("book not found")
+
+func bookDetail(bookDB *sql.DB, filePath string) (BookDetail, error) {
+	book, err := database.GetBookByPath(bookDB, filePath)
+	if err != nil || book.MissingSince != 0 {
+		return BookDetail{}, errBookNotFound
+	}
+
+	contributors, err := database.GetBookContributors(bookDB, filePath)
+	if err != nil {
+		return BookDetail{}, err
+	}
+	identifiers, err := database.GetBookIdentifiers(bookDB, filePath)
+	if err != nil {
+		return BookDetail{}, err
+	}
+	override, err := database.GetOverride(bookDB, filePath)
+	if err != nil {
+		return BookDetail{}, err
+	}
+
+	// empty lists rather than null keep clients simple
+	if contributors == nil {
+		contributors = []database.Contributor{}
+	}
+	if identifiers == nil {
+		identifiers = []database.Identifier{}
+	}
+
+	return BookDetail{
+		Type:            book.Type,
+		Library:         libraryName(book.Path),
+		Path:            library.ToAPI(book.Path),
+		Cover:           strings.TrimPrefix(book.CoverPath, "/cache/covers"),
+		Title:           book.Title,
+		Contributors:    contributors,
+		Series:          book.Series,
+		SeriesNumber:    book.SeriesNumber,
+		Publisher:       book.Publisher,
+		Language:        book.Language,
+		Direction:       book.Direction,
+		Identifiers:     identifiers,
+		Description:     book.Description,
+		Published:       book.Published,
+		Pages:           book.Pages,
+		Rating:          book.Rating,
+		Edited:          editedFields(override),
+		Size:            book.Size,
+		AddedTime:       book.AddedTime,
+		LastModded:      book.LastModded,
+		LastOpened:      book.LastOpened,
+		CurrentPosition: book.CurrentPosition,
+		Progress:        book.Progress,
+	}, nil
+}
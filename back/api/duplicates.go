@@ -0,0 +1,57 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/duplicate"
+	"back/internal/library"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DuplicateEntry struct {
+	Type    string `json:"type"`
+	Library string `json:"library"`
+	Path    string `json:"path"`
+	Cover   string `json:"cover"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	Size    int64  `json:"size"`
+}
+
+func DuplicatesHandler(bookDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := duplicate.Find(bookDB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"exact":   duplicateGroups(report.Exact),
+			"likely":  duplicateGroups(report.Likely),
+			"pending": report.Pending,
+		})
+	}
+}
+
+func duplicateGroups(groups [][]database.BookData) [][]DuplicateEntry {
+	result := make([][]DuplicateEntry, len(groups))
+	for i, group := range groups {
+		result[i] = make([]DuplicateEntry, len(group))
+		for j, b := range group {
+			result[i][j] = DuplicateEntry{
+				Type:    b.Type,
+				Library: libraryName(b.Path),
+				Path:    library.ToAPI(b.Path),
+				Cover:   strings.TrimPrefix(b.CoverPath, "/cache/covers"),
+				Title:   b.Title,
+				Author:  b.Author,
+				Size:    b.Size,
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"back/internal/library"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LibraryEntry struct {
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	Direction string   `json:"direction"`
+	Formats   []string `json:"formats"`
+}
+
+func LibrariesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libs := library.All()
+
+		libraries := make([]LibraryEntry, len(libs))
+		for i, lib := range libs {
+			libraries[i] = LibraryEntry{
+				Name:      lib.Name,
+				Path:      lib.APIPath(),
+				Direction: lib.Direction,
+				Formats:   lib.Formats,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"libraries": libraries,
+		})
+	}
+}
+
+// libraryPrefix returns the path prefix selected by the "library" query
+// parameter, or "" when no library is selected.
+func libraryPrefix(c *gin.Context) (string, bool) {
+	name := c.DefaultQuery("library", "")
+	if name == "" {
+		return "", true
+	}
+
+	lib := library.Get(name)
+	if lib == nil {
+		return "", false
+	}
+
+	return lib.Root + "/", true
+}
+
+func libraryName(path string) string {
+	lib := library.ForPath(path)
+	if lib == nil {
+		return ""
+	}
+	return lib.Name
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/library"
+	"back/internal/scan"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetadataEdit is the body of PUT /api/book/metadata. Fields that are left
+// out keep their current value, and the fields listed in Reset go back to
+// the scanned value.
+type MetadataEdit struct {
+	Title        *string   `json:"title"`
+	Authors      *[]string `json:"authors"`
+	Series       *string   `json:"series"`
+	SeriesNumber *string   `json:"seriesNumber"`
+	Tags         *[]string `json:"tags"`
+	Reset        []string  `json:"reset"`
+}
+
+func MetadataHandler(bookDB, keywordDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.DefaultQuery("path", "")
+
+		filePath, _, err := library.ToFS(path)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
+		var edit MetadataEdit
+		if err := c.ShouldBindJSON(&edit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		if edit.Title != nil && strings.TrimSpace(*edit.Title) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title must not be empty"})
+			return
+		}
+
+		book, err := database.GetBookByPath(bookDB, filePath)
+		if err != nil || book.MissingSince != 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+			return
+		}
+
+		o, err := database.GetOverride(bookDB, filePath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		for _, field := range edit.Reset {
+			switch field {
+			case "title":
+				o.Title = nil
+			case "authors":
+				o.Authors = nil
+			case "series":
+				o.Series = nil
+				o.SeriesNumber = nil
+			case "tags":
+				o.Tags = nil
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + field})
+				return
+			}
+		}
+
+		if edit.Title != nil {
+			o.Title = edit.Title
+		}
+		if edit.Authors != nil {
+			o.Authors = edit.Authors
+		}
+		if edit.Series != nil || edit.SeriesNumber != nil {
+			series, number := book.Series, book.SeriesNumber
+			if edit.Series != nil {
+				series = *edit.Series
+			}
+			if edit.SeriesNumber != nil {
+				number = *edit.SeriesNumber
+			}
+			o.Series = &series
+			o.SeriesNumber = &number
+		}
+		if edit.Tags != nil {
+			o.Tags = edit.Tags
+		}
+
+		if err := scan.SetOverride(filePath, o, bookDB, keywordDB); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save metadata"})
+			return
+		}
+
+		detail, err := bookDetail(bookDB, filePath)
+		if err != nil {
+			if errors.Is(err, errBookNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, detail)
+	}
+}
+
+func editedFields(o database.Override) []string {
+	edited := []string{}
+	if o.Title != nil {
+		edited = append(edited, "title")
+	}
+	if o.Authors != nil {
+		edited = append(edited, "authors")
+	}
+	if o.Series != nil {
+		edited = append(edited, "series")
+	}
+	if o.Tags != nil {
+		edited = append(edited, "tags")
+	}
+	return edited
+}
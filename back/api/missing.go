@@ -0,0 +1,108 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/library"
+	"back/internal/scan"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MissingEntry struct {
+	Type            string  `json:"type"`
+	Library         string  `json:"library"`
+	Path            string  `json:"path"`
+	Cover           string  `json:"cover"`
+	Title           string  `json:"title"`
+	CurrentPosition string  `json:"currentPosition"`
+	Progress        float64 `json:"progress"`
+	MissingSince    int64   `json:"missingSince"`
+}
+
+func MissingHandler(bookDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booksData, err := database.GetMissingBooks(bookDB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		books := make([]MissingEntry, len(booksData))
+		for i, b := range booksData {
+			books[i] = MissingEntry{
+				Type:            b.Type,
+				Library:         libraryName(b.Path),
+				Path:            library.ToAPI(b.Path),
+				Cover:           strings.TrimPrefix(b.CoverPath, "/cache/covers"),
+				Title:           b.Title,
+				CurrentPosition: b.CurrentPosition,
+				Progress:        b.Progress,
+				MissingSince:    b.MissingSince,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"books": books,
+		})
+	}
+}
+
+func PurgeMissingHandler(bookDB, keywordDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pathParam := c.DefaultQuery("path", "")
+
+		filePath := ""
+		if pathParam != "" {
+			var err error
+			filePath, _, err = library.ToFS(pathParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+				return
+			}
+		}
+
+		if err := scan.PurgeMissing(filePath, bookDB, keywordDB); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, "")
+	}
+}
+
+func RestoreMissingHandler(bookDB, keywordDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pathParam := c.DefaultQuery("path", "")
+		toParam := c.DefaultQuery("to", "")
+
+		filePath, _, err := library.ToFS(pathParam)
+		if pathParam == "" || err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
+		targetPath := ""
+		if toParam != "" {
+			targetPath, _, err = library.ToFS(toParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target path"})
+				return
+			}
+		}
+
+		if err := scan.RestoreMissing(filePath, targetPath, bookDB, keywordDB); err != nil {
+			if errors.Is(err, scan.ErrFileNotFound) {
+				c.JSON(http.StatusConflict, gin.H{"error": "file is still missing"})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, "")
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"back/database"
+	"back/internal/library"
 	"database/sql"
 	"net/http"
 	"strconv"
@@ -20,7 +21,13 @@ func ProgressHandler(bookDB *sql.DB) gin.HandlerFunc {
 			progress = 0.0
 		}
 
-		database.UpdateBookPositionAndProgress(bookDB, "/books"+path, currentPosition, progress)
+		filePath, _, err := library.ToFS(path)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
+		database.UpdateBookPositionAndProgress(bookDB, filePath, currentPosition, progress)
 
 		c.JSON(http.StatusOK, "")
 	}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"back/internal/render"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RenderStatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.GetMetrics())
+	}
+}
@@ -2,11 +2,11 @@ package api
 
 import (
 	"back/database"
+	"back/internal/library"
 	"database/sql"
 	"fmt"
 	"net/http"
 	"path"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -16,7 +16,6 @@ import (
 func RootHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pathParam := c.Param("path")
-		folderPath := filepath.Join("/books", pathParam)
 
 		sortBy := c.DefaultQuery("sort", "title")
 		order := c.DefaultQuery("order", "asc")
@@ -44,13 +43,24 @@ func RootHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 			order = "ASC"
 		}
 
+		if len(library.All()) > 1 && strings.Trim(pathParam, "/") == "" {
+			librariesAsFolders(c, bookDB)
+			return
+		}
+
+		folderPath, _, err := library.ToFS(pathParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
 		// folder
 
 		var subfolders []database.ChildFolder
 		if page == 1 {
 			subfolders, err = database.GetSubfolders(bookDB, folderPath)
 			if err != nil {
-				fmt.Println("failed to get subfolders: %v", err)
+				fmt.Printf("failed to get subfolders: %v\n", err)
 			}
 		}
 
@@ -75,7 +85,8 @@ func RootHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 		for i, f := range subfolders {
 			books[i] = BookEntry{
 				Type:            "Folder",
-				Path:            strings.TrimPrefix(f.Path, "/books"),
+				Library:         libraryName(f.Path),
+				Path:            library.ToAPI(f.Path),
 				Cover:           strings.TrimPrefix(f.CoverPath, "/cache/covers"),
 				Title:           path.Base(f.Path),
 				CurrentPosition: "",
@@ -86,7 +97,8 @@ func RootHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 		for i, b := range booksData {
 			books[i+subNum] = BookEntry{
 				Type:            b.Type,
-				Path:            strings.TrimPrefix(b.Path, "/books"),
+				Library:         libraryName(b.Path),
+				Path:            library.ToAPI(b.Path),
 				Cover:           strings.TrimPrefix(b.CoverPath, "/cache/covers"),
 				Title:           b.Title,
 				CurrentPosition: b.CurrentPosition,
@@ -100,3 +112,32 @@ func RootHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
 		})
 	}
 }
+
+// librariesAsFolders lists every library as a folder at the top level when
+// more than one library is configured.
+func librariesAsFolders(c *gin.Context, bookDB *sql.DB) {
+	libs := library.All()
+
+	books := make([]BookEntry, len(libs))
+	for i, lib := range libs {
+		coverPath, err := database.GetFirstCoverPath(bookDB, lib.Root)
+		if err != nil {
+			fmt.Printf("failed to get library cover: %v\n", err)
+		}
+
+		books[i] = BookEntry{
+			Type:            "Folder",
+			Library:         lib.Name,
+			Path:            lib.APIPath(),
+			Cover:           strings.TrimPrefix(coverPath, "/cache/covers"),
+			Title:           lib.Name,
+			CurrentPosition: "",
+			Progress:        0.0,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"books":   books,
+		"hasMore": false,
+	})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/library"
+	"back/internal/scan"
+	"database/sql"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ScanHandler(bookDB, keywordDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pathParam := c.DefaultQuery("path", "")
+
+		if strings.Trim(pathParam, "/") == "" {
+			queued := scan.Trigger("", bookDB, keywordDB)
+
+			c.JSON(http.StatusAccepted, gin.H{
+				"path":   "/",
+				"queued": queued,
+			})
+			return
+		}
+
+		root, _, err := library.ToFS(pathParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path"})
+			return
+		}
+
+		info, err := os.Stat(root)
+		if err != nil {
+			if os.IsNotExist(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "folder not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			}
+			return
+		}
+		if !info.IsDir() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "not a folder"})
+			return
+		}
+
+		queued := scan.Trigger(root, bookDB, keywordDB)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"path":   library.ToAPI(root),
+			"queued": queued,
+		})
+	}
+}
+
+type ScanErrorEntry struct {
+	Path    string `json:"path"`
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+	Stderr  string `json:"stderr"`
+	Time    int64  `json:"time"`
+}
+
+func ScanErrorsHandler(bookDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scanErrs, err := database.GetScanErrors(bookDB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		errs := make([]ScanErrorEntry, len(scanErrs))
+		for i, e := range scanErrs {
+			errs[i] = ScanErrorEntry{
+				Path:    library.ToAPI(e.Path),
+				Stage:   e.Stage,
+				Message: e.Message,
+				Stderr:  e.Stderr,
+				Time:    e.Time,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"errors": errs,
+		})
+	}
+}
+
+func ScanStatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, scan.GetStatus())
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"back/database"
+	"back/internal/library"
 	"database/sql"
 	"net/http"
 	"net/url"
@@ -65,6 +66,12 @@ func SearchHandler(bookDB, keywordDB *sql.DB, pageSize int) gin.HandlerFunc {
 			}
 		}
 
+		pathPrefix, ok := libraryPrefix(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown library"})
+			return
+		}
+
 		offset := (page - 1) * pageSize
 
 		var booksData []database.BookData
@@ -74,15 +81,15 @@ func SearchHandler(bookDB, keywordDB *sql.DB, pageSize int) gin.HandlerFunc {
 			if err != nil {
 				break
 			}
-			booksData, err = database.SearchBooksInPathListWithTitlePrefix(bookDB, pathList, titleQuery, sortColumn, order, pageSize+1, offset)
+			booksData, err = database.SearchBooksInPathListWithTitlePrefix(bookDB, pathPrefix, pathList, titleQuery, sortColumn, order, pageSize+1, offset)
 		case titleQuery == "" && len(keywordsList) != 0:
 			pathList, err := database.FindPathsByKeywords(keywordDB, keywordsList)
 			if err != nil {
 				break
 			}
-			booksData, err = database.SearchBooksInPathList(bookDB, pathList, sortColumn, order, pageSize+1, offset)
+			booksData, err = database.SearchBooksInPathList(bookDB, pathPrefix, pathList, sortColumn, order, pageSize+1, offset)
 		case titleQuery != "" && len(keywordsList) == 0:
-			booksData, err = database.SearchBooksWithTitlePrefix(bookDB, titleQuery, sortColumn, order, pageSize+1, offset)
+			booksData, err = database.SearchBooksWithTitlePrefix(bookDB, pathPrefix, titleQuery, sortColumn, order, pageSize+1, offset)
 		}
 
 		if err != nil {
@@ -100,7 +107,8 @@ func SearchHandler(bookDB, keywordDB *sql.DB, pageSize int) gin.HandlerFunc {
 		for i, b := range booksData {
 			books[i] = BookEntry{
 				Type:            b.Type,
-				Path:            strings.TrimPrefix(b.Path, "/books"),
+				Library:         libraryName(b.Path),
+				Path:            library.ToAPI(b.Path),
 				Cover:           strings.TrimPrefix(b.CoverPath, "/cache/covers"),
 				Title:           b.Title,
 				CurrentPosition: b.CurrentPosition,
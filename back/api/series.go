@@ -0,0 +1,162 @@
+package api
+
+import (
+	"back/database"
+	"back/internal/library"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SeriesEntry struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Cover string `json:"cover"`
+	Books int    `json:"books"`
+	// Finished counts the books read to the end.
+	Finished int     `json:"finished"`
+	Progress float64 `json:"progress"`
+}
+
+type SeriesBookEntry struct {
+	BookEntry
+	SeriesNumber string `json:"seriesNumber"`
+}
+
+func SeriesHandler(bookDB *sql.DB, pageSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sortBy := c.DefaultQuery("sort", "name")
+		order := c.DefaultQuery("order", "asc")
+		pageStr := c.DefaultQuery("page", "1")
+
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		validSorts := map[string]string{
+			"name":        "s.name_key",
+			"added_time":  "MAX(b.added_time)",
+			"last_opened": "MAX(b.last_opened)",
+			"progress":    "AVG(b.progress)",
+			"books":       "COUNT(*)",
+		}
+
+		sortColumn, ok := validSorts[sortBy]
+		if !ok {
+			sortColumn = "s.name_key"
+		}
+
+		order = strings.ToUpper(order)
+		if order != "ASC" && order != "DESC" {
+			order = "ASC"
+		}
+
+		pathPrefix, ok := libraryPrefix(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown library"})
+			return
+		}
+
+		offset := (page - 1) * pageSize
+
+		seriesData, err := database.GetSeries(bookDB, pathPrefix, sortColumn, order, pageSize+1, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		hasMore := false
+		if len(seriesData) > pageSize {
+			hasMore = true
+			seriesData = seriesData[:pageSize]
+		}
+
+		series := make([]SeriesEntry, len(seriesData))
+		for i, s := range seriesData {
+			series[i] = SeriesEntry{
+				ID:       s.ID,
+				Name:     s.Name,
+				Cover:    strings.TrimPrefix(s.CoverPath, "/cache/covers"),
+				Books:    s.Books,
+				Finished: s.Finished,
+				Progress: s.Progress,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"series":  series,
+			"hasMore": hasMore,
+		})
+	}
+}
+
+// SeriesBooksHandler returns the books of one series ordered by volume.
+func SeriesBooksHandler(bookDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+
+		pathPrefix, ok := libraryPrefix(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown library"})
+			return
+		}
+
+		s, err := database.GetSeriesByID(bookDB, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "series not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			}
+			return
+		}
+
+		booksData, err := database.GetBooksInSeries(bookDB, id, pathPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB query failed"})
+			return
+		}
+
+		finished := 0
+		progress := 0.0
+		books := make([]SeriesBookEntry, len(booksData))
+		for i, b := range booksData {
+			books[i] = SeriesBookEntry{
+				BookEntry: BookEntry{
+					Type:            b.Type,
+					Library:         libraryName(b.Path),
+					Path:            library.ToAPI(b.Path),
+					Cover:           strings.TrimPrefix(b.CoverPath, "/cache/covers"),
+					Title:           b.Title,
+					CurrentPosition: b.CurrentPosition,
+					Progress:        b.Progress,
+				},
+				SeriesNumber: b.SeriesNumber,
+			}
+			if b.Progress >= 1 {
+				finished++
+			}
+			progress += b.Progress
+		}
+		if len(books) > 0 {
+			progress /= float64(len(books))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"id":       s.ID,
+			"name":     s.Name,
+			"books":    books,
+			"finished": finished,
+			"progress": progress,
+		})
+	}
+}
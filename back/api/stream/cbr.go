@@ -1,8 +1,11 @@
 package stream
 
 import (
-	"bufio"
-	"bytes"
+	"back/database"
+	"back/internal/archive"
+	"back/internal/library"
+	"back/internal/pageindex"
+	"database/sql"
 	"io"
 	"log"
 	"net/http"
@@ -10,14 +13,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CBRPagesHandler() gin.HandlerFunc {
+func CBRPagesHandler(bookDB *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pathParam := c.DefaultQuery("path", "")
 		if pathParam == "" {
@@ -31,8 +33,11 @@ func CBRPagesHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		if _, err := os.Stat(filePath); err != nil {
 			if os.IsNotExist(err) {
@@ -43,51 +48,21 @@ func CBRPagesHandler() gin.HandlerFunc {
 			return
 		}
 
-		cmdList := exec.Command("7z", "l", "-ba", filePath)
-		outList, err := cmdList.Output()
+		pages, err := pageindex.Get(bookDB, filePath, "CBR")
 		if err != nil {
-			log.Printf("7z list command failed: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-
-		scanner := bufio.NewScanner(bytes.NewReader(outList))
-		var files []string
-		imgExts := []string{".jpg", ".jpeg", ".png", ".webp", ".bmp"}
-		for scanner.Scan() {
-			line := scanner.Text()
-			cols := strings.Fields(line)
-			if len(cols) < 6 {
-				continue
-			}
-			name := cols[len(cols)-1]
-			ext := strings.ToLower(filepath.Ext(name))
-			for _, e := range imgExts {
-				if ext == e {
-					files = append(files, name)
-					break
-				}
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			log.Printf("scanner error: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-
-		if len(files) == 0 {
-			log.Printf("no image files found in CBR archive")
+			log.Printf("failed to index CBR archive: %v", err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"pages": len(files),
+			"pages":      len(pages),
+			"dimensions": pageDimensions(pages),
 		})
 	}
 }
 
-func CBRStreamHandler() gin.HandlerFunc {
+func CBRStreamHandler(bookDB *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pathParam := c.DefaultQuery("path", "")
 		pageParam := c.DefaultQuery("page", "")
@@ -102,8 +77,11 @@ func CBRStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		page, err := strconv.Atoi(pageParam)
 		if err != nil {
@@ -111,6 +89,12 @@ func CBRStreamHandler() gin.HandlerFunc {
 			return
 		}
 
+		o, err := parsePageOptions(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
 		if _, err := os.Stat(filePath); err != nil {
 			if os.IsNotExist(err) {
 				c.Status(http.StatusNotFound)
@@ -120,81 +104,61 @@ func CBRStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		cmdList := exec.Command("7z", "l", "-ba", filePath)
-		outList, err := cmdList.Output()
+		pages, err := pageindex.Get(bookDB, filePath, "CBR")
 		if err != nil {
-			log.Printf("7z list command failed: %v", err)
+			log.Printf("failed to index CBR archive: %v", err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 
-		scanner := bufio.NewScanner(bytes.NewReader(outList))
-		var files []string
-		imgExts := []string{".jpg", ".jpeg", ".png", ".webp", ".bmp"}
-		for scanner.Scan() {
-			line := scanner.Text()
-			cols := strings.Fields(line)
-			if len(cols) < 6 {
-				continue
-			}
-			name := cols[len(cols)-1]
-			ext := strings.ToLower(filepath.Ext(name))
-			for _, e := range imgExts {
-				if ext == e {
-					files = append(files, name)
-					break
-				}
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			log.Printf("scanner error: %v", err)
-			c.Status(http.StatusInternalServerError)
+		if page < 1 || page > len(pages) {
+			c.String(http.StatusBadRequest, "invalid page number")
 			return
 		}
+		targetFile := pages[page-1].Name
 
-		if len(files) == 0 {
-			log.Printf("no image files found in CBR archive")
-			c.Status(http.StatusInternalServerError)
-			return
-		}
+		serve7zPage(c, bookDB, filePath, page, targetFile, o)
+	}
+}
 
-		sort.Strings(files)
-		if page < 1 || page > len(files) {
-			c.String(http.StatusBadRequest, "invalid page number")
-			return
-		}
-		targetFile := files[page-1]
+// serve7zPage streams a page of an archive that only 7z can read.
+func serve7zPage(c *gin.Context, bookDB *sql.DB, filePath string, page int, targetFile string, o pageOptions) {
+	if o.resized() {
+		serveResizedComicPage(c, bookDB, filePath, page, targetFile, o, func() ([]byte, error) {
+			return archive.Extract7z(filePath, targetFile)
+		})
+		return
+	}
 
-		cmdExtract := exec.Command("7z", "x", "-so", filePath, targetFile)
-		stdout, err := cmdExtract.StdoutPipe()
-		if err != nil {
-			log.Printf("failed to get stdout pipe: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-		if err := cmdExtract.Start(); err != nil {
-			log.Printf("failed to start 7z extract: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
+	cmdExtract := exec.Command("7z", "x", "-so", filePath, targetFile)
+	stdout, err := cmdExtract.StdoutPipe()
+	if err != nil {
+		log.Printf("failed to get stdout pipe: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if err := cmdExtract.Start(); err != nil {
+		log.Printf("failed to start 7z extract: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
 
-		mime := detectImageTypeByExt(targetFile)
+	mime := detectImageTypeByExt(targetFile)
 
-		c.Header("Content-Type", mime)
-		c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("Content-Type", mime)
+	c.Header("Cache-Control", "public, max-age=3600")
 
-		c.Status(http.StatusOK)
-		_, copyErr := io.Copy(c.Writer, stdout)
+	c.Status(http.StatusOK)
+	_, copyErr := io.Copy(c.Writer, stdout)
 
-		if copyErr != nil {
-			c.Status(http.StatusInternalServerError)
-			return
-		}
+	if copyErr != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
 
-		if err := cmdExtract.Wait(); err != nil {
-			c.Status(http.StatusInternalServerError)
-			return
-		}
+	if err := cmdExtract.Wait(); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
 	}
 }
 
@@ -214,3 +178,13 @@ func detectImageTypeByExt(path string) string {
 		return "application/octet-stream"
 	}
 }
+
+// pageDimensions lists the size of each page so that readers can lay out
+// spreads before the images load. Unknown sizes are 0.
+func pageDimensions(pages []database.Page) []gin.H {
+	dimensions := make([]gin.H, len(pages))
+	for i, p := range pages {
+		dimensions[i] = gin.H{"width": p.Width, "height": p.Height}
+	}
+	return dimensions
+}
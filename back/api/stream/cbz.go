@@ -1,23 +1,21 @@
 package stream
 
 import (
-	"bufio"
-	"bytes"
+	"back/internal/archive"
+	"back/internal/library"
+	"back/internal/pageindex"
+	"database/sql"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"sort"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CBZPagesHandler() gin.HandlerFunc {
+func CBZPagesHandler(bookDB *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pathParam := c.DefaultQuery("path", "")
 		if pathParam == "" {
@@ -31,8 +29,11 @@ func CBZPagesHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		if _, err := os.Stat(filePath); err != nil {
 			if os.IsNotExist(err) {
@@ -43,51 +44,21 @@ func CBZPagesHandler() gin.HandlerFunc {
 			return
 		}
 
-		cmdList := exec.Command("7z", "l", "-ba", filePath)
-		outList, err := cmdList.Output()
+		pages, err := pageindex.Get(bookDB, filePath, "CBZ")
 		if err != nil {
-			log.Printf("7z list command failed: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-
-		scanner := bufio.NewScanner(bytes.NewReader(outList))
-		var files []string
-		imgExts := []string{".jpg", ".jpeg", ".png", ".webp", ".bmp"}
-		for scanner.Scan() {
-			line := scanner.Text()
-			cols := strings.Fields(line)
-			if len(cols) < 6 {
-				continue
-			}
-			name := cols[len(cols)-1]
-			ext := strings.ToLower(filepath.Ext(name))
-			for _, e := range imgExts {
-				if ext == e {
-					files = append(files, name)
-					break
-				}
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			log.Printf("scanner error: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-
-		if len(files) == 0 {
-			log.Printf("no image files found in CBZ archive")
+			log.Printf("failed to index CBZ archive: %v", err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"pages": len(files),
+			"pages":      len(pages),
+			"dimensions": pageDimensions(pages),
 		})
 	}
 }
 
-func CBZStreamHandler() gin.HandlerFunc {
+func CBZStreamHandler(bookDB *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pathParam := c.DefaultQuery("path", "")
 		pageParam := c.DefaultQuery("page", "")
@@ -102,8 +73,11 @@ func CBZStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		page, err := strconv.Atoi(pageParam)
 		if err != nil {
@@ -111,6 +85,12 @@ func CBZStreamHandler() gin.HandlerFunc {
 			return
 		}
 
+		o, err := parsePageOptions(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
 		if _, err := os.Stat(filePath); err != nil {
 			if os.IsNotExist(err) {
 				c.Status(http.StatusNotFound)
@@ -120,63 +100,41 @@ func CBZStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		cmdList := exec.Command("7z", "l", "-ba", filePath)
-		outList, err := cmdList.Output()
+		pages, err := pageindex.Get(bookDB, filePath, "CBZ")
 		if err != nil {
-			log.Printf("7z list command failed: %v", err)
+			log.Printf("failed to index CBZ archive: %v", err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 
-		scanner := bufio.NewScanner(bytes.NewReader(outList))
-		var files []string
-		imgExts := []string{".jpg", ".jpeg", ".png", ".webp", ".bmp"}
-		for scanner.Scan() {
-			line := scanner.Text()
-			cols := strings.Fields(line)
-			if len(cols) < 6 {
-				continue
-			}
-			name := cols[len(cols)-1]
-			ext := strings.ToLower(filepath.Ext(name))
-			for _, e := range imgExts {
-				if ext == e {
-					files = append(files, name)
-					break
-				}
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			log.Printf("scanner error: %v", err)
-			c.Status(http.StatusInternalServerError)
+		if page < 1 || page > len(pages) {
+			c.String(http.StatusBadRequest, "invalid page number")
 			return
 		}
+		targetFile := pages[page-1].Name
 
-		if len(files) == 0 {
-			log.Printf("no image files found in CBZ archive")
-			c.Status(http.StatusInternalServerError)
+		ar, err := archive.Open(filePath)
+		if err != nil {
+			// many .cbz files are RAR or 7z archives that were only renamed
+			serve7zPage(c, bookDB, filePath, page, targetFile, o)
 			return
 		}
+		defer ar.Close()
 
-		sort.Strings(files)
-		if page < 1 || page > len(files) {
-			c.String(http.StatusBadRequest, "invalid page number")
+		if o.resized() {
+			serveResizedComicPage(c, bookDB, filePath, page, targetFile, o, func() ([]byte, error) {
+				return ar.ReadFile(targetFile)
+			})
 			return
 		}
-		targetFile := files[page-1]
 
-		cmdExtract := exec.Command("7z", "x", "-so", filePath, targetFile)
-		stdout, err := cmdExtract.StdoutPipe()
+		entry, err := ar.Open(targetFile)
 		if err != nil {
-			log.Printf("failed to get stdout pipe: %v", err)
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-		if err := cmdExtract.Start(); err != nil {
-			log.Printf("failed to start 7z extract: %v", err)
+			log.Printf("failed to open %s: %v", targetFile, err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
+		defer entry.Close()
 
 		mime := detectImageTypeByExt(targetFile)
 
@@ -184,16 +142,8 @@ func CBZStreamHandler() gin.HandlerFunc {
 		c.Header("Cache-Control", "public, max-age=3600")
 
 		c.Status(http.StatusOK)
-		_, copyErr := io.Copy(c.Writer, stdout)
-
-		if copyErr != nil {
-			c.Status(http.StatusInternalServerError)
-			return
-		}
-
-		if err := cmdExtract.Wait(); err != nil {
-			c.Status(http.StatusInternalServerError)
-			return
+		if _, err := io.Copy(c.Writer, entry); err != nil {
+			log.Printf("failed to stream %s: %v", targetFile, err)
 		}
 	}
 }
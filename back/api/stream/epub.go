@@ -1,11 +1,10 @@
 package stream
 
 import (
+	"back/internal/library"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,8 +23,11 @@ func EPUBStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		if _, err := os.Stat(filePath); err != nil {
 			if os.IsNotExist(err) {
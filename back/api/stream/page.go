@@ -0,0 +1,213 @@
+package stream
+
+import (
+	"back/database"
+	"back/internal/fingerprint"
+	"back/internal/imaging"
+	"back/internal/render"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pageQuality int
+
+func init() {
+	pageQuality = getEnvInt("PAGE_QUALITY", 85)
+}
+
+const (
+	minDPI = 36
+	maxDPI = 600
+)
+
+// pageSizes and pageDPIs are the bounds and resolutions pages are served at.
+// Requests are rounded up to one of them, so that clients with slightly
+// different screens share renders instead of each filling the cache.
+var (
+	pageSizes = []int{320, 480, 640, 800, 1024, 1280, 1600, 1920, 2560, 3200, 3840}
+	pageDPIs  = []int{36, 72, 96, 150, 200, 300, 400, 600}
+)
+
+// snap rounds n up to the nearest step, or down to the largest one.
+func snap(n int, steps []int) int {
+	for _, step := range steps {
+		if n <= step {
+			return step
+		}
+	}
+	return steps[len(steps)-1]
+}
+
+// pageOptions are the query parameters that let clients ask for a page
+// smaller or in another format than the original.
+type pageOptions struct {
+	width    int
+	height   int
+	dpi      int
+	format   string
+	priority render.Priority
+}
+
+func parsePageOptions(c *gin.Context) (pageOptions, error) {
+	o := pageOptions{priority: render.High}
+
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"width", &o.width}, {"height", &o.height}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return o, fmt.Errorf("invalid %s", p.name)
+			}
+			if n > 0 {
+				*p.value = snap(n, pageSizes)
+			}
+		}
+	}
+
+	if v := c.Query("dpi"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minDPI || n > maxDPI {
+			return o, fmt.Errorf("invalid dpi, must be between %d and %d", minDPI, maxDPI)
+		}
+		o.dpi = snap(n, pageDPIs)
+	}
+
+	switch format := strings.ToLower(c.Query("format")); format {
+	case "":
+	case "jpg", imaging.JPEG:
+		o.format = imaging.JPEG
+	case imaging.WebP, imaging.PNG:
+		o.format = format
+	default:
+		return o, fmt.Errorf("invalid format, must be webp, jpeg or png")
+	}
+
+	// readers fetching pages ahead ask for them with prefetch=true, so that
+	// they wait behind the page being viewed
+	if prefetch, _ := strconv.ParseBool(c.Query("prefetch")); prefetch {
+		o.priority = render.Low
+	}
+
+	return o, nil
+}
+
+// resized reports whether the page has to be scaled or converted.
+func (o pageOptions) resized() bool {
+	return o.width > 0 || o.height > 0 || o.format != ""
+}
+
+// formatOf returns the format of an image file, for pages that are scaled
+// without asking for another format.
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return imaging.JPEG
+	case ".webp":
+		return imaging.WebP
+	default:
+		return imaging.PNG
+	}
+}
+
+func fileExt(format string) string {
+	if format == imaging.JPEG {
+		return "jpg"
+	}
+	return format
+}
+
+// writePage scales an image to the requested bounds and writes it to
+// outPath in format.
+func writePage(data []byte, o pageOptions, format, outPath string) error {
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.Fit(img, o.width, o.height), format, pageQuality); err != nil {
+		return fmt.Errorf("failed to encode page: %w", err)
+	}
+	return os.WriteFile(outPath, buf.Bytes(), 0644)
+}
+
+// serveResizedComicPage serves a comic page scaled and converted as the
+// client asked. The result goes through the render cache and queue like a
+// PDF page.
+func serveResizedComicPage(c *gin.Context, bookDB *sql.DB, filePath string, page int, targetFile string, o pageOptions, read func() ([]byte, error)) {
+	key, err := bookRenderKey(bookDB, filePath)
+	if err != nil {
+		log.Printf("failed to fingerprint %s: %v", filePath, err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	format := o.format
+	if format == "" {
+		format = formatOf(targetFile)
+	}
+
+	ctx := c.Request.Context()
+	key.Page, key.Width, key.Height, key.Format = page, o.width, o.height, fileExt(format)
+	f, err := render.Open(ctx, key, o.priority, func(ctx context.Context, outPath string) error {
+		data, err := read()
+		if err != nil {
+			return err
+		}
+		return writePage(data, o, format, outPath)
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to resize %s: %v", targetFile, err)
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+
+	serveRendered(c, f, format)
+}
+
+// bookRenderKey returns the part of a render key that identifies the book:
+// the fingerprint stored by the scanner and the modification time of the
+// file. Books that have not been scanned yet are fingerprinted on the spot.
+func bookRenderKey(bookDB *sql.DB, filePath string) (render.Key, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return render.Key{}, err
+	}
+
+	key := render.Key{ModTime: info.ModTime().Unix()}
+	if book, err := database.GetBookByPath(bookDB, filePath); err == nil && book.Fingerprint != "" {
+		key.Fingerprint = book.Fingerprint
+		return key, nil
+	}
+	_, key.Fingerprint, err = fingerprint.Compute(filePath)
+	return key, err
+}
+
+// serveRendered sends a page from the render cache.
+func serveRendered(c *gin.Context, f *os.File, format string) {
+	info, err := f.Stat()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", imaging.ContentType(format))
+	c.Header("Cache-Control", "public, max-age=3600")
+
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), f)
+}
@@ -1,18 +1,26 @@
 package stream
 
 import (
+	"back/database"
+	"back/internal/imaging"
+	"back/internal/library"
+	"back/internal/pdf"
+	"back/internal/render"
 	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -46,8 +54,11 @@ func PDFPagesHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		// Check if file exists (consider adding path validation as needed)
 		if _, err := os.Stat(filePath); err != nil {
@@ -91,7 +102,73 @@ func PDFPagesHandler() gin.HandlerFunc {
 	}
 }
 
-func PDFStreamHandler() gin.HandlerFunc {
+// PDFTOCHandler returns the outline and page labels of a PDF. They are read
+// once and kept in the database until the file changes.
+func PDFTOCHandler(bookDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pathParam := c.Query("path")
+		if pathParam == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing 'path' parameter"})
+			return
+		}
+
+		decodedPath, err := url.PathUnescape(pathParam)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			}
+			return
+		}
+		modTime := info.ModTime().Unix()
+
+		data, stored, ok, err := database.GetPDFTOC(bookDB, filePath)
+		if err != nil {
+			log.Printf("failed to get PDF table of contents: %v", err)
+		}
+		if ok && stored == modTime {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+			return
+		}
+
+		toc, err := pdf.ReadTOC(filePath)
+		if errors.Is(err, pdf.ErrEncrypted) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("failed to read PDF table of contents: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read table of contents"})
+			return
+		}
+
+		data, err = json.Marshal(toc)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if err := database.SetPDFTOC(bookDB, filePath, modTime, data); err != nil {
+			log.Printf("failed to store PDF table of contents: %v", err)
+		}
+
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	}
+}
+
+func PDFStreamHandler(bookDB *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pathParam := c.DefaultQuery("path", "")
 		pageParam := c.DefaultQuery("page", "")
@@ -106,8 +183,11 @@ func PDFStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		rawPath := strings.TrimPrefix(decodedPath, "/")
-		filePath := filepath.Join("/books", rawPath)
+		filePath, _, err := library.ToFS(decodedPath)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
 		page, err := strconv.Atoi(pageParam)
 		if err != nil || page < 1 {
@@ -115,6 +195,18 @@ func PDFStreamHandler() gin.HandlerFunc {
 			return
 		}
 
+		o, err := parsePageOptions(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if o.dpi == 0 {
+			o.dpi = dpi
+		}
+		if o.format == "" {
+			o.format = imaging.PNG
+		}
+
 		if _, err := os.Stat(filePath); err != nil {
 			if os.IsNotExist(err) {
 				c.Status(http.StatusNotFound)
@@ -124,34 +216,119 @@ func PDFStreamHandler() gin.HandlerFunc {
 			return
 		}
 
-		tmpFile := fmt.Sprintf("pdf-%d.tmp.png", page)
-
-		cmdList := exec.Command("gs",
-			"-sDEVICE=png16m",
-			"-dUseCIEColor=false",
-			fmt.Sprintf("-dFirstPage=%d", page),
-			fmt.Sprintf("-dLastPage=%d", page),
-			fmt.Sprintf("-r%d", dpi),
-			"-dNOPAUSE",
-			"-dBATCH",
-			"-sOutputFile="+tmpFile,
-			filePath,
-		)
-
-		if err := cmdList.Run(); err != nil {
-			log.Printf("ghostscript command failed: %v", err)
+		bookKey, err := bookRenderKey(bookDB, filePath)
+		if err != nil {
+			log.Printf("failed to fingerprint %s: %v", filePath, err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 
-		c.Header("Content-Type", "image/png")
-		c.Header("Cache-Control", "public, max-age=3600")
+		ctx := c.Request.Context()
+		f, err := openPDFPage(ctx, filePath, bookKey, page, o)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("ghostscript command failed: %v", err)
+				c.Status(http.StatusInternalServerError)
+			}
+			return
+		}
+		defer f.Close()
+
+		if o.priority == render.High {
+			var pageCount int
+			if book, err := database.GetBookByPath(bookDB, filePath); err == nil {
+				pageCount = book.Pages
+			}
+			prerenderAhead(c.ClientIP(), filePath, bookKey, page, pageCount, o)
+		}
+
+		serveRendered(c, f, o.format)
+	}
+}
+
+// pdfPageKey completes bookKey, as returned by bookRenderKey, for a page.
+func pdfPageKey(bookKey render.Key, page int, o pageOptions) render.Key {
+	key := bookKey
+	key.Page, key.DPI, key.Width, key.Height, key.Format = page, o.dpi, o.width, o.height, fileExt(o.format)
+	return key
+}
 
-		c.File(tmpFile)
+// openPDFPage returns a page from the render cache, rendering it first if
+// needed. o must have its dpi and format set.
+func openPDFPage(ctx context.Context, filePath string, bookKey render.Key, page int, o pageOptions) (*os.File, error) {
+	return render.Open(ctx, pdfPageKey(bookKey, page, o), o.priority, func(ctx context.Context, outPath string) error {
+		dpi := pageDPI(filePath, page, o.dpi)
+		if o.format == imaging.PNG && o.width == 0 && o.height == 0 {
+			return renderPDFPage(ctx, filePath, page, dpi, outPath)
+		}
 
-		go func(file string) {
-			time.Sleep(2 * time.Second)
-			os.Remove(file)
-		}(tmpFile)
+		// gs writes PNG, which is then scaled and converted
+		pngPath := outPath + ".png"
+		defer os.Remove(pngPath)
+		if err := renderPDFPage(ctx, filePath, page, dpi, pngPath); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(pngPath)
+		if err != nil {
+			return err
+		}
+		return writePage(data, o, o.format, outPath)
+	})
+}
+
+// pageDPI lowers dpi for pages that would render to more than
+// imaging.MaxPixels, such as maps and posters, which could not be scaled or
+// converted afterwards.
+func pageDPI(filePath string, page, dpi int) int {
+	width, height, err := pdfPageSize(filePath, page)
+	if err != nil {
+		return dpi
+	}
+
+	pixels := width * height * float64(dpi*dpi) / (72 * 72)
+	if pixels <= imaging.MaxPixels {
+		return dpi
+	}
+	return max(1, int(float64(dpi)*math.Sqrt(imaging.MaxPixels/pixels)))
+}
+
+// pdfPageSize returns the size of a page in points (1/72 inch), as reported
+// by pdfinfo.
+func pdfPageSize(filePath string, page int) (float64, float64, error) {
+	out, err := exec.Command("pdfinfo",
+		"-f", strconv.Itoa(page),
+		"-l", strconv.Itoa(page),
+		filePath,
+	).Output()
+	if err != nil {
+		return 0, 0, err
 	}
+
+	// e.g. "Page    3 size: 612 x 792 pts (letter)"
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var n int
+		var width, height float64
+		if _, err := fmt.Sscanf(scanner.Text(), "Page %d size: %g x %g", &n, &width, &height); err == nil && n == page {
+			return width, height, nil
+		}
+	}
+	return 0, 0, errors.New("page size not found")
+}
+
+// renderPDFPage runs Ghostscript for a single page. The process is killed
+// when ctx is done.
+func renderPDFPage(ctx context.Context, filePath string, page, dpi int, outPath string) error {
+	cmd := exec.CommandContext(ctx, "gs",
+		"-sDEVICE=png16m",
+		"-dUseCIEColor=false",
+		fmt.Sprintf("-dFirstPage=%d", page),
+		fmt.Sprintf("-dLastPage=%d", page),
+		fmt.Sprintf("-r%d", dpi),
+		"-dNOPAUSE",
+		"-dBATCH",
+		"-sOutputFile="+outPath,
+		filePath,
+	)
+	return cmd.Run()
 }
@@ -0,0 +1,166 @@
+package stream
+
+import (
+	"back/database"
+	"back/internal/imaging"
+	"back/internal/render"
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	idleInterval = time.Minute
+	// idleBooks is how many books of each kind are pre-rendered when idle.
+	idleBooks = 5
+)
+
+var (
+	prerenderPages int
+	prerenderIdle  bool
+)
+
+func init() {
+	prerenderPages = getEnvInt("PRERENDER_PAGES", 3)
+	prerenderIdle, _ = strconv.ParseBool(os.Getenv("PRERENDER_IDLE"))
+}
+
+// lookahead is the pre-rendering started by the last page request of a
+// reader of a book.
+type lookahead struct {
+	cancel context.CancelFunc
+}
+
+// lookaheadKey tells readers apart, so that two people reading the same book,
+// or one reader with two devices, do not cancel each other's lookahead.
+type lookaheadKey struct {
+	path   string
+	client string
+	dpi    int
+	width  int
+	height int
+	format string
+}
+
+var (
+	lookaheadMu sync.Mutex
+	lookaheads  = make(map[lookaheadKey]*lookahead)
+)
+
+// prerenderAhead renders the PRERENDER_PAGES pages after page in the
+// background, so that turning the page does not wait for Ghostscript. It
+// replaces the lookahead of an earlier request of the same reader, since the
+// reader has moved on from there. pageCount is 0 when it is not known.
+func prerenderAhead(client, filePath string, bookKey render.Key, page, pageCount int, o pageOptions) {
+	if prerenderPages <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	la := &lookahead{cancel: cancel}
+
+	key := lookaheadKey{filePath, client, o.dpi, o.width, o.height, o.format}
+	lookaheadMu.Lock()
+	if prev, ok := lookaheads[key]; ok {
+		prev.cancel()
+	}
+	lookaheads[key] = la
+	lookaheadMu.Unlock()
+
+	o.priority = render.Low
+	go func() {
+		defer func() {
+			lookaheadMu.Lock()
+			if lookaheads[key] == la {
+				delete(lookaheads, key)
+			}
+			lookaheadMu.Unlock()
+			cancel()
+		}()
+
+		for p := page + 1; p <= page+prerenderPages; p++ {
+			if pageCount > 0 && p > pageCount {
+				return
+			}
+			if !prerender(ctx, filePath, bookKey, p, o) {
+				return
+			}
+		}
+	}()
+}
+
+// prerender renders a page into the cache unless it is there already, and
+// reports whether the next page is worth trying.
+func prerender(ctx context.Context, filePath string, bookKey render.Key, page int, o pageOptions) bool {
+	if render.Cached(pdfPageKey(bookKey, page, o)) {
+		return true
+	}
+
+	f, err := openPDFPage(ctx, filePath, bookKey, page, o)
+	if err != nil {
+		// past the last page, or canceled
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// PrerenderIdle renders the first pages of recently added books and the
+// current pages of the books being read while no pages are being rendered
+// for readers. It only runs when PRERENDER_IDLE is set, and uses the
+// default page options.
+func PrerenderIdle(bookDB *sql.DB) {
+	if !prerenderIdle || prerenderPages <= 0 {
+		return
+	}
+
+	o := pageOptions{dpi: dpi, format: imaging.PNG, priority: render.Low}
+
+	ticker := time.NewTicker(idleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !renderIdle() {
+			continue
+		}
+
+		books, err := database.GetBooksToPrerender(bookDB, idleBooks)
+		if err != nil {
+			log.Printf("failed to list books to pre-render: %v", err)
+			continue
+		}
+
+	books:
+		for _, book := range books {
+			bookKey, err := bookRenderKey(bookDB, book.Path)
+			if err != nil {
+				continue
+			}
+
+			start := 1
+			if p, err := strconv.Atoi(book.CurrentPosition); err == nil && p > 0 {
+				start = p
+			}
+			for p := start; p < start+prerenderPages; p++ {
+				if book.Pages > 0 && p > book.Pages {
+					break
+				}
+				// stop as soon as a reader needs the renderer
+				if !renderIdle() {
+					break books
+				}
+				if !prerender(context.Background(), book.Path, bookKey, p, o) {
+					break
+				}
+			}
+		}
+	}
+}
+
+func renderIdle() bool {
+	m := render.GetMetrics()
+	return m.Running == 0 && m.Queued == 0 && m.QueuedPrefetch == 0
+}
@@ -2,6 +2,7 @@ package api
 
 type BookEntry struct {
 	Type            string  `json:"type"`
+	Library         string  `json:"library"`
 	Path            string  `json:"path"`
 	Cover           string  `json:"cover"`
 	Title           string  `json:"title"`
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type AuthorData struct {
+	ID       int64
+	Name     string
+	SortName string
+	Books    int
+}
+
+func createAuthorTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS authors (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			sort_name TEXT NOT NULL,
+			name_key TEXT NOT NULL UNIQUE
+		);
+	`)
+	return err
+}
+
+// authorKey ignores case, punctuation and spacing, so that "J.R.R. Tolkien"
+// and "J. R. R. Tolkien" are the same author.
+func authorKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// addAuthor creates the author with the given name. An existing author with
+// the same key keeps its spelling, and its sort name is only replaced by one
+// that sortGiven marks as curated, such as a Calibre author sort or an OPF
+// file-as, so that guesses from other books do not overwrite it.
+func addAuthor(db Execer, name, sortName string, sortGiven bool) error {
+	if sortName == "" {
+		sortName = name
+	}
+
+	if !sortGiven {
+		_, err := db.Exec(`
+			INSERT INTO authors (name, sort_name, name_key)
+			VALUES (?, ?, ?)
+			ON CONFLICT(name_key) DO NOTHING
+		`, name, sortName, authorKey(name))
+		return err
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO authors (name, sort_name, name_key)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name_key) DO UPDATE SET sort_name = excluded.sort_name
+	`, name, sortName, authorKey(name))
+	return err
+}
+
+// DeleteEmptyAuthors removes authors that no book refers to anymore.
+func DeleteEmptyAuthors(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM authors WHERE id NOT IN (SELECT author_id FROM book_contributors)`)
+	return err
+}
+
+// GetAuthors lists the authors with at least one book below pathPrefix. An
+// empty role counts books in any role.
+func GetAuthors(db *sql.DB, pathPrefix, role, sortBy, order string, limit, offset int) ([]AuthorData, error) {
+	query := fmt.Sprintf(`
+		SELECT a.id, a.name, a.sort_name, COUNT(DISTINCT b.path)
+		FROM authors a
+		JOIN book_contributors c ON c.author_id = a.id
+		JOIN books b ON b.path = c.path
+		WHERE b.path LIKE ? AND b.missing_since = 0 AND (? = '' OR c.role = ?)
+		GROUP BY a.id
+		ORDER BY %s %s
+		LIMIT ? OFFSET ?`, sortBy, order)
+
+	rows, err := db.Query(query, pathPrefix+"%", role, role, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authors []AuthorData
+	for rows.Next() {
+		var a AuthorData
+		if err := rows.Scan(&a.ID, &a.Name, &a.SortName, &a.Books); err != nil {
+			return nil, err
+		}
+		authors = append(authors, a)
+	}
+	return authors, rows.Err()
+}
+
+func GetAuthorByID(db *sql.DB, id int64) (*AuthorData, error) {
+	var a AuthorData
+	err := db.QueryRow(`SELECT id, name, sort_name FROM authors WHERE id = ?`, id).
+		Scan(&a.ID, &a.Name, &a.SortName)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func GetBooksByAuthor(db *sql.DB, id int64, pathPrefix, sortBy, order string, limit, offset int) ([]BookData, error) {
+	query := fmt.Sprintf(`
+		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress
+		FROM books
+		WHERE path IN (SELECT path FROM book_contributors WHERE author_id = ?)
+		  AND path LIKE ? AND missing_since = 0
+		ORDER BY %s %s
+		LIMIT ? OFFSET ?`, sortBy, order)
+
+	rows, err := db.Query(query, id, pathPrefix+"%", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []BookData
+	for rows.Next() {
+		var b BookData
+		if err := rows.Scan(
+			&b.Path,
+			&b.CoverPath,
+			&b.Type,
+			&b.Title,
+			&b.AddedTime,
+			&b.LastModded,
+			&b.LastOpened,
+			&b.CurrentPosition,
+			&b.Progress,
+		); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
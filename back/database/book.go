@@ -21,10 +21,32 @@ type BookData struct {
 	LastOpened      int64   `json:"last_opened"`
 	CurrentPosition string  `json:"current_position"`
 	Progress        float64 `json:"progress"`
+	Size            int64   `json:"size"`
+	Fingerprint     string  `json:"fingerprint"`
+	MissingSince    int64   `json:"missing_since"`
+	Author          string  `json:"author"`
+	ContentHash     string  `json:"content_hash"`
+	Series          string  `json:"series"`
+	SeriesNumber    string  `json:"series_number"`
+	Publisher       string  `json:"publisher"`
+	Language        string  `json:"language"`
+	Direction       string  `json:"direction"`
+	MetaVersion     int     `json:"meta_version"`
+	Description     string  `json:"description"`
+	Published       string  `json:"published"`
+	Pages           int     `json:"pages"`
+	Rating          float64 `json:"rating"`
+	// SeriesIndex is the numeric form of SeriesNumber, nil when it has none.
+	SeriesIndex *float64 `json:"series_index"`
+	// Contributors and Identifiers are written by AddBook and
+	// UpdateBookDetails, but only read by GetBookContributors and
+	// GetBookIdentifiers.
+	Contributors []Contributor `json:"contributors"`
+	Identifiers  []Identifier  `json:"identifiers"`
 }
 
 func OpenBookDB() *sql.DB {
-	db, err := sql.Open("sqlite", "/db/book.db")
+	db, err := sql.Open("sqlite", "/db/book.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	if err != nil {
 		panic(err)
 	}
@@ -39,23 +61,118 @@ func OpenBookDB() *sql.DB {
 			last_modded INTEGER,
 			last_opened INTEGER,
 			current_position TEXT,
-			progress REAL
+			progress REAL,
+			size INTEGER DEFAULT 0,
+			fingerprint TEXT DEFAULT '',
+			missing_since INTEGER DEFAULT 0,
+			author TEXT,
+			content_hash TEXT DEFAULT '',
+			series TEXT DEFAULT '',
+			series_number TEXT DEFAULT '',
+			publisher TEXT DEFAULT '',
+			language TEXT DEFAULT '',
+			direction TEXT DEFAULT '',
+			meta_version INTEGER DEFAULT 0,
+			description TEXT DEFAULT '',
+			published TEXT DEFAULT '',
+			pages INTEGER DEFAULT 0,
+			series_id INTEGER DEFAULT 0,
+			series_index REAL,
+			rating REAL DEFAULT 0,
+			scanned_meta TEXT DEFAULT '',
+			cover_version INTEGER DEFAULT 0
 		);
 	`)
 	if err != nil {
 		panic(err)
 	}
 
+	if err := addColumnIfMissing(db, "books", "size", "INTEGER DEFAULT 0"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "fingerprint", "TEXT DEFAULT ''"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "missing_since", "INTEGER DEFAULT 0"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "author", "TEXT"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "content_hash", "TEXT DEFAULT ''"); err != nil {
+		panic(err)
+	}
+	for _, column := range []string{"series", "series_number", "publisher", "language", "direction", "description", "published"} {
+		if err := addColumnIfMissing(db, "books", column, "TEXT DEFAULT ''"); err != nil {
+			panic(err)
+		}
+	}
+	// existing books get version 0 and are extracted again by the next scan
+	if err := addColumnIfMissing(db, "books", "meta_version", "INTEGER DEFAULT 0"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "pages", "INTEGER DEFAULT 0"); err != nil {
+		panic(err)
+	}
+
+	if err := addColumnIfMissing(db, "books", "series_id", "INTEGER DEFAULT 0"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "series_index", "REAL"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "rating", "REAL DEFAULT 0"); err != nil {
+		panic(err)
+	}
+	if err := addColumnIfMissing(db, "books", "scanned_meta", "TEXT DEFAULT ''"); err != nil {
+		panic(err)
+	}
+	// existing books get version 0 and their covers are extracted again when
+	// the extraction of their format has changed
+	if err := addColumnIfMissing(db, "books", "cover_version", "INTEGER DEFAULT 0"); err != nil {
+		panic(err)
+	}
+
+	if err := createSeriesTable(db); err != nil {
+		panic(err)
+	}
+	if err := createAuthorTable(db); err != nil {
+		panic(err)
+	}
+	if err := createDetailTables(db); err != nil {
+		panic(err)
+	}
+	if err := createOverrideTable(db); err != nil {
+		panic(err)
+	}
+	if err := createPageIndexTable(db); err != nil {
+		panic(err)
+	}
+	if err := createPDFTOCTable(db); err != nil {
+		panic(err)
+	}
+
+	if err := createScanErrorTable(db); err != nil {
+		panic(err)
+	}
+
 	return db
 }
 
 type PathModded struct {
-	Path       string
-	LastModded int64
+	Path         string
+	LastModded   int64
+	MissingSince int64
 }
 
-func GetPathAndLastModdedList(db *sql.DB) ([]PathModded, error) {
-	rows, err := db.Query(`SELECT path, last_modded FROM books`)
+// GetPathAndLastModdedListByPrefix returns the book stored at path itself and
+// every book stored below it when path is a directory.
+func GetPathAndLastModdedListByPrefix(db *sql.DB, path string) ([]PathModded, error) {
+	dirPath := strings.TrimSuffix(path, "/") + "/"
+
+	rows, err := db.Query(`
+		SELECT path, last_modded, missing_since FROM books
+		WHERE path = ? OR path LIKE ?`, path, dirPath+"%")
 	if err != nil {
 		return nil, err
 	}
@@ -64,9 +181,13 @@ func GetPathAndLastModdedList(db *sql.DB) ([]PathModded, error) {
 	var results []PathModded
 	for rows.Next() {
 		var pm PathModded
-		if err := rows.Scan(&pm.Path, &pm.LastModded); err != nil {
+		if err := rows.Scan(&pm.Path, &pm.LastModded, &pm.MissingSince); err != nil {
 			return nil, err
 		}
+		// LIKE treats '_' and '%' in file names as wildcards
+		if pm.Path != path && !strings.HasPrefix(pm.Path, dirPath) {
+			continue
+		}
 		results = append(results, pm)
 	}
 
@@ -77,7 +198,7 @@ func GetPathAndLastModdedList(db *sql.DB) ([]PathModded, error) {
 	return results, nil
 }
 
-func AddBook(db *sql.DB, book BookData) error {
+func AddBook(db Execer, book BookData) error {
 	_, err := db.Exec(`
 		INSERT INTO books (
 			path,
@@ -88,8 +209,21 @@ func AddBook(db *sql.DB, book BookData) error {
 			last_modded,
 			last_opened,
 			current_position,
-			progress
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			progress,
+			size,
+			fingerprint,
+			author,
+			series,
+			series_number,
+			publisher,
+			language,
+			direction,
+			meta_version,
+			description,
+			published,
+			pages,
+			rating
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		book.Path,
 		book.CoverPath,
@@ -100,14 +234,37 @@ func AddBook(db *sql.DB, book BookData) error {
 		book.LastOpened,
 		book.CurrentPosition,
 		book.Progress,
+		book.Size,
+		book.Fingerprint,
+		book.Author,
+		book.Series,
+		book.SeriesNumber,
+		book.Publisher,
+		book.Language,
+		book.Direction,
+		book.MetaVersion,
+		book.Description,
+		book.Published,
+		book.Pages,
+		book.Rating,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := setBookSeries(db, book.Path, book.Series, book.SeriesIndex); err != nil {
+		return err
+	}
+	return setBookDetails(db, book.Path, book.Contributors, book.Identifiers)
 }
 
-func GetBookByPath(db *sql.DB, path string) (*BookData, error) {
+func GetBookByPath(db Querier, path string) (*BookData, error) {
 	row := db.QueryRow(`
 		SELECT path, cover_path, type, title, added_time,
-		       last_modded, last_opened, current_position, progress
+		       last_modded, last_opened, current_position, progress,
+		       size, fingerprint, missing_since, COALESCE(author, ''), content_hash,
+		       series, series_number, publisher, language, direction, meta_version,
+		       description, published, pages, rating
 		FROM books
 		WHERE path = ?`, path)
 
@@ -122,6 +279,21 @@ func GetBookByPath(db *sql.DB, path string) (*BookData, error) {
 		&book.LastOpened,
 		&book.CurrentPosition,
 		&book.Progress,
+		&book.Size,
+		&book.Fingerprint,
+		&book.MissingSince,
+		&book.Author,
+		&book.ContentHash,
+		&book.Series,
+		&book.SeriesNumber,
+		&book.Publisher,
+		&book.Language,
+		&book.Direction,
+		&book.MetaVersion,
+		&book.Description,
+		&book.Published,
+		&book.Pages,
+		&book.Rating,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -133,7 +305,7 @@ func GetBookByPath(db *sql.DB, path string) (*BookData, error) {
 	return &book, nil
 }
 
-func UpdateBookTitleAndModTime(db *sql.DB, path string, title string, lastModded int64) error {
+func UpdateBookTitleAndModTime(db Execer, path string, title string, lastModded int64) error {
 	_, err := db.Exec(`
 		UPDATE books
 		SET title = ?, last_modded = ?
@@ -142,6 +314,238 @@ func UpdateBookTitleAndModTime(db *sql.DB, path string, title string, lastModded
 	return err
 }
 
+func UpdateBookTitle(db Execer, path string, title string) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET title = ?
+		WHERE path = ?
+	`, title, path)
+	return err
+}
+
+// UpdateBookFingerprint also clears the cached content hash, which is only
+// valid for the content it was computed from.
+func UpdateBookFingerprint(db Execer, path string, size int64, fingerprint string) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET size = ?, fingerprint = ?, content_hash = ''
+		WHERE path = ?
+	`, size, fingerprint, path)
+	return err
+}
+
+func UpdateBookContentHash(db Execer, path string, contentHash string) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET content_hash = ?
+		WHERE path = ?
+	`, contentHash, path)
+	return err
+}
+
+// UpdateBookDetails stores the metadata fields beyond the title.
+func UpdateBookDetails(db Execer, book BookData) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET author = ?, series = ?, series_number = ?, publisher = ?,
+		    language = ?, direction = ?, meta_version = ?,
+		    description = ?, published = ?, pages = ?, rating = ?
+		WHERE path = ?
+	`,
+		book.Author,
+		book.Series,
+		book.SeriesNumber,
+		book.Publisher,
+		book.Language,
+		book.Direction,
+		book.MetaVersion,
+		book.Description,
+		book.Published,
+		book.Pages,
+		book.Rating,
+		book.Path,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := setBookSeries(db, book.Path, book.Series, book.SeriesIndex); err != nil {
+		return err
+	}
+	return setBookDetails(db, book.Path, book.Contributors, book.Identifiers)
+}
+
+type PathType struct {
+	Path string
+	Type string
+}
+
+// GetPathsWithOldMeta returns the books at or below root whose metadata was
+// extracted by an older version of the extractors. An empty root matches
+// every book.
+func GetPathsWithOldMeta(db *sql.DB, version int, root string) ([]PathType, error) {
+	dirPath := strings.TrimSuffix(root, "/") + "/"
+
+	rows, err := db.Query(`
+		SELECT path, type FROM books
+		WHERE meta_version < ? AND missing_since = 0 AND (? = '' OR path = ? OR path LIKE ?)`,
+		version, root, root, dirPath+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []PathType
+	for rows.Next() {
+		var pt PathType
+		if err := rows.Scan(&pt.Path, &pt.Type); err != nil {
+			return nil, err
+		}
+		// LIKE treats '_' and '%' in file names as wildcards
+		if root != "" && pt.Path != root && !strings.HasPrefix(pt.Path, dirPath) {
+			continue
+		}
+		results = append(results, pt)
+	}
+
+	return results, rows.Err()
+}
+
+// UpdateBookMetaVersion marks the metadata of a book as extracted by version
+// without changing it, for books whose extraction failed.
+func UpdateBookMetaVersion(db Execer, path string, version int) error {
+	_, err := db.Exec(`UPDATE books SET meta_version = ? WHERE path = ?`, version, path)
+	return err
+}
+
+// GetBooksWithOldCover returns the books of bookType at or below root whose
+// cover was extracted by an older version. An empty root matches every book.
+func GetBooksWithOldCover(db *sql.DB, bookType string, version int, root string) ([]BookData, error) {
+	dirPath := strings.TrimSuffix(root, "/") + "/"
+
+	rows, err := db.Query(`
+		SELECT path, cover_path, type FROM books
+		WHERE type = ? AND cover_version < ? AND missing_since = 0 AND (? = '' OR path = ? OR path LIKE ?)`,
+		bookType, version, root, root, dirPath+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []BookData
+	for rows.Next() {
+		var book BookData
+		if err := rows.Scan(&book.Path, &book.CoverPath, &book.Type); err != nil {
+			return nil, err
+		}
+		// LIKE treats '_' and '%' in file names as wildcards
+		if root != "" && book.Path != root && !strings.HasPrefix(book.Path, dirPath) {
+			continue
+		}
+		results = append(results, book)
+	}
+
+	return results, rows.Err()
+}
+
+// UpdateBookCover stores the cover of a book and the version of the
+// extraction it was made with.
+func UpdateBookCover(db Execer, path, coverPath string, version int) error {
+	_, err := db.Exec(`UPDATE books SET cover_path = ?, cover_version = ? WHERE path = ?`, coverPath, version, path)
+	return err
+}
+
+// GetBooksForDuplicates returns every book that is not missing, with the
+// fields the duplicate detector compares.
+func GetBooksForDuplicates(db *sql.DB) ([]BookData, error) {
+	rows, err := db.Query(`
+		SELECT path, cover_path, type, title, current_position, progress,
+		       size, fingerprint, COALESCE(author, ''), content_hash
+		FROM books
+		WHERE missing_since = 0
+		ORDER BY path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []BookData
+	for rows.Next() {
+		var b BookData
+		if err := rows.Scan(
+			&b.Path,
+			&b.CoverPath,
+			&b.Type,
+			&b.Title,
+			&b.CurrentPosition,
+			&b.Progress,
+			&b.Size,
+			&b.Fingerprint,
+			&b.Author,
+			&b.ContentHash,
+		); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+
+	return books, rows.Err()
+}
+
+// MoveBook re-points a book to a new path, keeping its reading state.
+func MoveBook(db Execer, oldPath, newPath, coverPath string, lastModded int64) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET path = ?, cover_path = ?, last_modded = ?, missing_since = 0
+		WHERE path = ?
+	`, newPath, coverPath, lastModded, oldPath)
+	if err != nil {
+		return err
+	}
+
+	if err := moveBookDetails(db, oldPath, newPath); err != nil {
+		return err
+	}
+	if err := movePageIndex(db, oldPath, newPath); err != nil {
+		return err
+	}
+	if err := movePDFTOC(db, oldPath, newPath); err != nil {
+		return err
+	}
+	return moveOverride(db, oldPath, newPath)
+}
+
+// GetPathsWithoutFingerprint returns the books at or below root, or all
+// books when root is empty, that have not been fingerprinted yet.
+func GetPathsWithoutFingerprint(db *sql.DB, root string) ([]string, error) {
+	dirPath := strings.TrimSuffix(root, "/") + "/"
+
+	rows, err := db.Query(`
+		SELECT path FROM books
+		WHERE (fingerprint IS NULL OR fingerprint = '') AND missing_since = 0
+			AND (? = '' OR path = ? OR path LIKE ?)`,
+		root, root, dirPath+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		// LIKE treats '_' and '%' in file names as wildcards
+		if root != "" && path != root && !strings.HasPrefix(path, dirPath) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, rows.Err()
+}
+
 func UpdateBookPositionAndProgress(db *sql.DB, path string, currentPosition string, progress float64) error {
 	_, err := db.Exec(`
 		UPDATE books
@@ -161,19 +565,158 @@ func UpdateBookLastOpened(db *sql.DB, path string) error {
 	return err
 }
 
-func DeleteBookByPath(db *sql.DB, path string) error {
-	_, err := db.Exec(`DELETE FROM books WHERE path = ?`, path)
+// MarkBookMissing hides a book whose file has disappeared but keeps its row,
+// so the reading state survives until the book is purged. The original
+// timestamp is kept when the book is already missing.
+func MarkBookMissing(db Execer, path string, since int64) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET missing_since = ?
+		WHERE path = ? AND missing_since = 0
+	`, since, path)
 	return err
 }
 
-func GetBooksFlat(db *sql.DB, sortBy, order string, limit, offset int) ([]BookData, error) {
+func RestoreBook(db Execer, path string) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET missing_since = 0
+		WHERE path = ?
+	`, path)
+	return err
+}
+
+// TransferReadingState copies the reading state of one book to another, e.g.
+// from a missing book to the copy that replaced it.
+func TransferReadingState(db Execer, fromPath, toPath string) error {
+	_, err := db.Exec(`
+		UPDATE books
+		SET (last_opened, current_position, progress) =
+		    (SELECT last_opened, current_position, progress FROM books WHERE path = ?)
+		WHERE path = ?
+	`, fromPath, toPath)
+	return err
+}
+
+func GetMissingBooks(db *sql.DB) ([]BookData, error) {
+	rows, err := db.Query(`
+		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress, missing_since
+		FROM books
+		WHERE missing_since > 0
+		ORDER BY missing_since DESC, path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []BookData
+	for rows.Next() {
+		var b BookData
+		if err := rows.Scan(
+			&b.Path,
+			&b.CoverPath,
+			&b.Type,
+			&b.Title,
+			&b.AddedTime,
+			&b.LastModded,
+			&b.LastOpened,
+			&b.CurrentPosition,
+			&b.Progress,
+			&b.MissingSince,
+		); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
+
+// GetMissingPathsBefore returns the books that have been missing since before
+// the given time.
+func GetMissingPathsBefore(db *sql.DB, before int64) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT path FROM books
+		WHERE missing_since > 0 AND missing_since < ?`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// GetBooksToPrerender returns the PDFs a reader is likely to open next: up to
+// limit books that are being read, most recently opened first, followed by
+// up to limit of the most recently added ones.
+func GetBooksToPrerender(db *sql.DB, limit int) ([]BookData, error) {
+	rows, err := db.Query(`
+		SELECT path, current_position, pages FROM (
+			SELECT path, current_position, pages
+			FROM books
+			WHERE type = 'PDF' AND missing_since = 0 AND last_opened > 0 AND progress < 1
+			ORDER BY last_opened DESC
+			LIMIT ?
+		)
+		UNION ALL
+		SELECT path, current_position, pages FROM (
+			SELECT path, '' AS current_position, pages
+			FROM books
+			WHERE type = 'PDF' AND missing_since = 0 AND last_opened = 0
+			ORDER BY added_time DESC
+			LIMIT ?
+		)`, limit, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []BookData
+	for rows.Next() {
+		var b BookData
+		var position sql.NullString
+		if err := rows.Scan(&b.Path, &position, &b.Pages); err != nil {
+			return nil, err
+		}
+		b.CurrentPosition = position.String
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
+
+func DeleteBookByPath(db *sql.DB, path string) error {
+	if _, err := db.Exec(`DELETE FROM books WHERE path = ?`, path); err != nil {
+		return err
+	}
+
+	if err := deleteBookDetails(db, path); err != nil {
+		return err
+	}
+	if err := DeletePageIndex(db, path); err != nil {
+		return err
+	}
+	if err := DeletePDFTOC(db, path); err != nil {
+		return err
+	}
+	return deleteOverride(db, path)
+}
+
+func GetBooksFlat(db *sql.DB, pathPrefix, sortBy, order string, limit, offset int) ([]BookData, error) {
 	query := fmt.Sprintf(`
 		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress
 		FROM books
+		WHERE path LIKE ? AND missing_since = 0
 		ORDER BY %s %s
 		LIMIT ? OFFSET ?`, sortBy, order)
 
-	rows, err := db.Query(query, limit, offset)
+	rows, err := db.Query(query, pathPrefix+"%", limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -208,7 +751,7 @@ func GetBooksFolder(db *sql.DB, folderPath, sortBy, order string, limit, offset
 	query := fmt.Sprintf(`
 		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress
 		FROM books
-		WHERE path LIKE ? AND
+		WHERE path LIKE ? AND missing_since = 0 AND
 		      LENGTH(REPLACE(SUBSTR(path, LENGTH(?) + 1), '/', '')) = LENGTH(SUBSTR(path, LENGTH(?) + 1))
 		ORDER BY %s %s
 		LIMIT ? OFFSET ?`, sortBy, order)
@@ -240,6 +783,24 @@ func GetBooksFolder(db *sql.DB, folderPath, sortBy, order string, limit, offset
 	return books, nil
 }
 
+// GetFirstCoverPath returns the cover of the first book below folderPath.
+func GetFirstCoverPath(db *sql.DB, folderPath string) (string, error) {
+	if !strings.HasSuffix(folderPath, "/") {
+		folderPath += "/"
+	}
+
+	var coverPath string
+	err := db.QueryRow(`
+		SELECT cover_path FROM books
+		WHERE path LIKE ? AND missing_since = 0
+		ORDER BY path
+		LIMIT 1`, folderPath+"%").Scan(&coverPath)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return coverPath, err
+}
+
 type ChildFolder struct {
 	Path      string
 	CoverPath string
@@ -252,7 +813,7 @@ func GetSubfolders(db *sql.DB, folderPath string) ([]ChildFolder, error) {
 
 	rows, err := db.Query(`
 		SELECT path, cover_path FROM books
-		WHERE path LIKE ?
+		WHERE path LIKE ? AND missing_since = 0
 		ORDER BY path;
 	`, folderPath+"%")
 	if err != nil {
@@ -309,6 +870,7 @@ func GetSubfolders(db *sql.DB, folderPath string) ([]ChildFolder, error) {
 
 func SearchBooksInPathListWithTitlePrefix(
 	db *sql.DB,
+	pathPrefix string,
 	pathList []string,
 	titleLike string,
 	sortBy, order string,
@@ -321,18 +883,19 @@ func SearchBooksInPathListWithTitlePrefix(
 	placeholders := strings.Repeat("?,", len(pathList))
 	placeholders = placeholders[:len(placeholders)-1]
 
-	args := make([]interface{}, len(pathList)+3)
+	args := make([]interface{}, len(pathList)+4)
 	for i, p := range pathList {
 		args[i] = p
 	}
-	args[len(pathList)] = titleLike + "%"
-	args[len(pathList)+1] = limit
-	args[len(pathList)+2] = offset
+	args[len(pathList)] = pathPrefix + "%"
+	args[len(pathList)+1] = titleLike + "%"
+	args[len(pathList)+2] = limit
+	args[len(pathList)+3] = offset
 
 	query := fmt.Sprintf(`
 		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress
 		FROM books
-		WHERE path IN (%s) AND title LIKE ?
+		WHERE path IN (%s) AND path LIKE ? AND title LIKE ? AND missing_since = 0
 		ORDER BY %s %s
 		LIMIT ? OFFSET ?;
 	`, placeholders, sortBy, strings.ToUpper(order))
@@ -367,6 +930,7 @@ func SearchBooksInPathListWithTitlePrefix(
 
 func SearchBooksInPathList(
 	db *sql.DB,
+	pathPrefix string,
 	pathList []string,
 	sortBy, order string,
 	limit, offset int,
@@ -378,17 +942,18 @@ func SearchBooksInPathList(
 	placeholders := strings.Repeat("?,", len(pathList))
 	placeholders = placeholders[:len(placeholders)-1]
 
-	args := make([]interface{}, len(pathList)+2)
+	args := make([]interface{}, len(pathList)+3)
 	for i, p := range pathList {
 		args[i] = p
 	}
-	args[len(pathList)] = limit
-	args[len(pathList)+1] = offset
+	args[len(pathList)] = pathPrefix + "%"
+	args[len(pathList)+1] = limit
+	args[len(pathList)+2] = offset
 
 	query := fmt.Sprintf(`
 		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress
 		FROM books
-		WHERE path IN (%s)
+		WHERE path IN (%s) AND path LIKE ? AND missing_since = 0
 		ORDER BY %s %s
 		LIMIT ? OFFSET ?;
 	`, placeholders, sortBy, strings.ToUpper(order))
@@ -423,16 +988,17 @@ func SearchBooksInPathList(
 
 func SearchBooksWithTitlePrefix(
 	db *sql.DB,
+	pathPrefix string,
 	titleLike string,
 	sortBy, order string,
 	limit, offset int,
 ) ([]BookData, error) {
-	args := []interface{}{titleLike + "%", limit, offset}
+	args := []interface{}{pathPrefix + "%", titleLike + "%", limit, offset}
 
 	query := fmt.Sprintf(`
 		SELECT path, cover_path, type, title, added_time, last_modded, last_opened, current_position, progress
 		FROM books
-		WHERE title LIKE ?
+		WHERE path LIKE ? AND title LIKE ? AND missing_since = 0
 		ORDER BY %s %s
 		LIMIT ? OFFSET ?;
 	`, sortBy, strings.ToUpper(order))
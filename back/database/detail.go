@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+)
+
+type Contributor struct {
+	AuthorID int64  `json:"authorId"`
+	Name     string `json:"name"`
+	SortName string `json:"sortName"`
+	Role     string `json:"role"`
+	// SortNameGiven is set when SortName comes from the file or Calibre
+	// rather than being guessed, and may then replace the stored one.
+	SortNameGiven bool `json:"-"`
+}
+
+type Identifier struct {
+	Scheme string `json:"scheme"`
+	Value  string `json:"value"`
+}
+
+// createDetailTables creates the tables for the metadata that can have
+// several values per book.
+func createDetailTables(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS book_contributors (
+			path TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			role TEXT NOT NULL,
+			author_id INTEGER DEFAULT 0,
+			PRIMARY KEY (path, position)
+		);
+		CREATE TABLE IF NOT EXISTS book_identifiers (
+			path TEXT NOT NULL,
+			scheme TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (path, scheme, value)
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(db, "book_contributors", "author_id", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS book_contributors_author ON book_contributors (author_id)`)
+	return err
+}
+
+// setBookDetails replaces the contributors and identifiers of a book.
+func setBookDetails(db Execer, path string, contributors []Contributor, identifiers []Identifier) error {
+	if err := deleteBookDetails(db, path); err != nil {
+		return err
+	}
+
+	for i, c := range contributors {
+		if authorKey(c.Name) == "" {
+			continue
+		}
+		if err := addAuthor(db, c.Name, c.SortName, c.SortNameGiven); err != nil {
+			return err
+		}
+
+		_, err := db.Exec(`
+			INSERT INTO book_contributors (path, position, name, role, author_id)
+			VALUES (?, ?, ?, ?, (SELECT id FROM authors WHERE name_key = ?))
+		`, path, i, c.Name, c.Role, authorKey(c.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range identifiers {
+		_, err := db.Exec(`
+			INSERT OR IGNORE INTO book_identifiers (path, scheme, value)
+			VALUES (?, ?, ?)
+		`, path, id.Scheme, id.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func moveBookDetails(db Execer, oldPath, newPath string) error {
+	if _, err := db.Exec(`UPDATE book_contributors SET path = ? WHERE path = ?`, newPath, oldPath); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE book_identifiers SET path = ? WHERE path = ?`, newPath, oldPath)
+	return err
+}
+
+func deleteBookDetails(db Execer, path string) error {
+	if _, err := db.Exec(`DELETE FROM book_contributors WHERE path = ?`, path); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM book_identifiers WHERE path = ?`, path)
+	return err
+}
+
+func GetBookContributors(db *sql.DB, path string) ([]Contributor, error) {
+	rows, err := db.Query(`
+		SELECT c.author_id, c.name, COALESCE(a.sort_name, c.name), c.role
+		FROM book_contributors c
+		LEFT JOIN authors a ON a.id = c.author_id
+		WHERE c.path = ?
+		ORDER BY c.position`, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contributors []Contributor
+	for rows.Next() {
+		var c Contributor
+		if err := rows.Scan(&c.AuthorID, &c.Name, &c.SortName, &c.Role); err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, c)
+	}
+
+	return contributors, rows.Err()
+}
+
+func GetBookIdentifiers(db *sql.DB, path string) ([]Identifier, error) {
+	rows, err := db.Query(`
+		SELECT scheme, value FROM book_identifiers
+		WHERE path = ?
+		ORDER BY scheme, value`, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identifiers []Identifier
+	for rows.Next() {
+		var id Identifier
+		if err := rows.Scan(&id.Scheme, &id.Value); err != nil {
+			return nil, err
+		}
+		identifiers = append(identifiers, id)
+	}
+
+	return identifiers, rows.Err()
+}
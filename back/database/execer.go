@@ -0,0 +1,16 @@
+package database
+
+import "database/sql"
+
+// Execer is implemented by both *sql.DB and *sql.Tx, so write helpers can be
+// used on their own or as part of a batch transaction.
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Querier is an Execer that can also read, for helpers that need to see the
+// uncommitted state of a transaction.
+type Querier interface {
+	Execer
+	QueryRow(query string, args ...any) *sql.Row
+}
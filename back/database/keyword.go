@@ -9,7 +9,7 @@ import (
 )
 
 func OpenKeywordDB() *sql.DB {
-	db, err := sql.Open("sqlite", "/db/keyword.db")
+	db, err := sql.Open("sqlite", "/db/keyword.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	if err != nil {
 		panic(err)
 	}
@@ -28,7 +28,7 @@ func OpenKeywordDB() *sql.DB {
 	return db
 }
 
-func AddKeyword(db *sql.DB, path string, keyword string) error {
+func AddKeyword(db Execer, path string, keyword string) error {
 	_, err := db.Exec(`
 		INSERT OR IGNORE INTO book_keywords (path, keyword)
 		VALUES (?, ?)
@@ -39,11 +39,16 @@ func AddKeyword(db *sql.DB, path string, keyword string) error {
 	return nil
 }
 
-func DeleteKeywordsByPath(db *sql.DB, path string) error {
+func DeleteKeywordsByPath(db Execer, path string) error {
 	_, err := db.Exec(`DELETE FROM book_keywords WHERE path = ?`, path)
 	return err
 }
 
+func MoveKeywords(db Execer, oldPath, newPath string) error {
+	_, err := db.Exec(`UPDATE book_keywords SET path = ? WHERE path = ?`, newPath, oldPath)
+	return err
+}
+
 func FindPathsByKeywords(db *sql.DB, keywordList []string) ([]string, error) {
 	if len(keywordList) == 0 {
 		return []string{}, nil
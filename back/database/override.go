@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// Override holds the metadata a user edited. A nil field is not overridden
+// and keeps the scanned value.
+type Override struct {
+	Title   *string
+	Authors *[]string
+	// Series and SeriesNumber are overridden together.
+	Series       *string
+	SeriesNumber *string
+	Tags         *[]string
+}
+
+func createOverrideTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS book_overrides (
+			path TEXT PRIMARY KEY,
+			title TEXT,
+			authors TEXT,
+			series TEXT,
+			series_number TEXT,
+			tags TEXT
+		);
+	`)
+	return err
+}
+
+func GetOverride(db Querier, path string) (Override, error) {
+	var o Override
+	var title, authors, series, seriesNumber, tags sql.NullString
+
+	err := db.QueryRow(`
+		SELECT title, authors, series, series_number, tags
+		FROM book_overrides
+		WHERE path = ?`, path).Scan(&title, &authors, &series, &seriesNumber, &tags)
+	if errors.Is(err, sql.ErrNoRows) {
+		return o, nil
+	}
+	if err != nil {
+		return o, err
+	}
+
+	if title.Valid {
+		o.Title = &title.String
+	}
+	if series.Valid {
+		o.Series = &series.String
+		o.SeriesNumber = &seriesNumber.String
+	}
+	if authors.Valid {
+		var list []string
+		if err := json.Unmarshal([]byte(authors.String), &list); err != nil {
+			return o, err
+		}
+		o.Authors = &list
+	}
+	if tags.Valid {
+		var list []string
+		if err := json.Unmarshal([]byte(tags.String), &list); err != nil {
+			return o, err
+		}
+		o.Tags = &list
+	}
+
+	return o, nil
+}
+
+// SetOverride replaces the edits of a book. The row is removed once no field
+// is overridden anymore.
+func SetOverride(db Execer, path string, o Override) error {
+	if o.Title == nil && o.Authors == nil && o.Series == nil && o.Tags == nil {
+		return deleteOverride(db, path)
+	}
+
+	var authors, tags, seriesNumber any
+	if o.Authors != nil {
+		data, err := json.Marshal(*o.Authors)
+		if err != nil {
+			return err
+		}
+		authors = string(data)
+	}
+	if o.Tags != nil {
+		data, err := json.Marshal(*o.Tags)
+		if err != nil {
+			return err
+		}
+		tags = string(data)
+	}
+	if o.Series != nil {
+		seriesNumber = ""
+		if o.SeriesNumber != nil {
+			seriesNumber = *o.SeriesNumber
+		}
+	}
+
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO book_overrides (path, title, authors, series, series_number, tags)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, path, o.Title, authors, o.Series, seriesNumber, tags)
+	return err
+}
+
+func moveOverride(db Execer, oldPath, newPath string) error {
+	_, err := db.Exec(`UPDATE book_overrides SET path = ? WHERE path = ?`, newPath, oldPath)
+	return err
+}
+
+func deleteOverride(db Execer, path string) error {
+	_, err := db.Exec(`DELETE FROM book_overrides WHERE path = ?`, path)
+	return err
+}
+
+// SetScannedMeta stores the metadata as extracted, before edits are applied,
+// so that an edit can be reset without extracting the book again.
+func SetScannedMeta(db Execer, path string, scanned string) error {
+	_, err := db.Exec(`UPDATE books SET scanned_meta = ? WHERE path = ?`, scanned, path)
+	return err
+}
+
+func GetScannedMeta(db Querier, path string) (string, error) {
+	var scanned string
+	err := db.QueryRow(`SELECT scanned_meta FROM books WHERE path = ?`, path).Scan(&scanned)
+	return scanned, err
+}
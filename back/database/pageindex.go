@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// Page is an image in a comic archive, in reading order.
+type Page struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func createPageIndexTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS page_index (
+			path TEXT PRIMARY KEY,
+			mod_time INTEGER NOT NULL,
+			pages TEXT NOT NULL
+		);
+	`)
+	return err
+}
+
+// GetPageIndex returns the stored pages of an archive and the modification
+// time they were read at. ok is false when the archive has not been indexed.
+func GetPageIndex(db *sql.DB, path string) (pages []Page, modTime int64, ok bool, err error) {
+	var data string
+	err = db.QueryRow(`SELECT mod_time, pages FROM page_index WHERE path = ?`, path).Scan(&modTime, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if err := json.Unmarshal([]byte(data), &pages); err != nil {
+		return nil, 0, false, err
+	}
+	return pages, modTime, true, nil
+}
+
+func SetPageIndex(db Execer, path string, modTime int64, pages []Page) error {
+	data, err := json.Marshal(pages)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO page_index (path, mod_time, pages) VALUES (?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET mod_time = excluded.mod_time, pages = excluded.pages
+	`, path, modTime, string(data))
+	return err
+}
+
+func DeletePageIndex(db Execer, path string) error {
+	_, err := db.Exec(`DELETE FROM page_index WHERE path = ?`, path)
+	return err
+}
+
+func movePageIndex(db Execer, oldPath, newPath string) error {
+	_, err := db.Exec(`UPDATE page_index SET path = ? WHERE path = ?`, newPath, oldPath)
+	return err
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+)
+
+type ScanError struct {
+	Path    string
+	Stage   string
+	Message string
+	Stderr  string
+	Time    int64
+}
+
+func createScanErrorTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS scan_errors (
+			path TEXT NOT NULL,
+			stage TEXT NOT NULL,
+			message TEXT,
+			stderr TEXT,
+			time INTEGER,
+			PRIMARY KEY (path, stage)
+		);
+	`)
+	return err
+}
+
+func AddScanError(db Execer, scanErr ScanError) error {
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO scan_errors (path, stage, message, stderr, time)
+		VALUES (?, ?, ?, ?, ?)
+	`, scanErr.Path, scanErr.Stage, scanErr.Message, scanErr.Stderr, scanErr.Time)
+	return err
+}
+
+func DeleteScanErrorsByPath(db Execer, path string) error {
+	_, err := db.Exec(`DELETE FROM scan_errors WHERE path = ?`, path)
+	return err
+}
+
+// DeleteScanError removes the error of a single stage, for steps that only
+// run that stage again.
+func DeleteScanError(db Execer, path, stage string) error {
+	_, err := db.Exec(`DELETE FROM scan_errors WHERE path = ? AND stage = ?`, path, stage)
+	return err
+}
+
+func GetScanErrors(db *sql.DB) ([]ScanError, error) {
+	rows, err := db.Query(`
+		SELECT path, stage, message, stderr, time
+		FROM scan_errors
+		ORDER BY time DESC, path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scanErrs []ScanError
+	for rows.Next() {
+		var e ScanError
+		if err := rows.Scan(&e.Path, &e.Stage, &e.Message, &e.Stderr, &e.Time); err != nil {
+			return nil, err
+		}
+		scanErrs = append(scanErrs, e)
+	}
+
+	return scanErrs, rows.Err()
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type SeriesData struct {
+	ID         int64
+	Name       string
+	CoverPath  string
+	Books      int
+	Finished   int
+	Progress   float64
+	AddedTime  int64
+	LastOpened int64
+}
+
+func createSeriesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS series (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			name_key TEXT NOT NULL UNIQUE
+		);
+	`)
+	return err
+}
+
+// seriesKey lets "One Piece" and "one  piece" share a series.
+func seriesKey(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// setBookSeries links a book to the series with the given name, creating
+// the series when it does not exist yet.
+func setBookSeries(db Execer, path, name string, index *float64) error {
+	key := seriesKey(name)
+	if key == "" {
+		_, err := db.Exec(`UPDATE books SET series_id = 0, series_index = NULL WHERE path = ?`, path)
+		return err
+	}
+
+	_, err := db.Exec(`INSERT OR IGNORE INTO series (name, name_key) VALUES (?, ?)`, strings.TrimSpace(name), key)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		UPDATE books
+		SET series_id = (SELECT id FROM series WHERE name_key = ?), series_index = ?
+		WHERE path = ?
+	`, key, index, path)
+	return err
+}
+
+// DeleteEmptySeries removes series that no book refers to anymore.
+func DeleteEmptySeries(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM series WHERE id NOT IN (SELECT series_id FROM books)`)
+	return err
+}
+
+// seriesOrder sorts the books of a series by volume. Books without a number
+// come last, by title.
+const seriesOrder = `series_index IS NULL, series_index, title`
+
+func GetSeries(db *sql.DB, pathPrefix, sortBy, order string, limit, offset int) ([]SeriesData, error) {
+	query := fmt.Sprintf(`
+		SELECT s.id, s.name,
+		       (SELECT cover_path FROM books c
+		        WHERE c.series_id = s.id AND c.path LIKE ? AND c.missing_since = 0
+		        ORDER BY %s LIMIT 1),
+		       COUNT(*), SUM(b.progress >= 1), AVG(b.progress),
+		       MAX(b.added_time), MAX(b.last_opened)
+		FROM series s
+		JOIN books b ON b.series_id = s.id
+		WHERE b.path LIKE ? AND b.missing_since = 0
+		GROUP BY s.id
+		ORDER BY %s %s
+		LIMIT ? OFFSET ?`, seriesOrder, sortBy, order)
+
+	rows, err := db.Query(query, pathPrefix+"%", pathPrefix+"%", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []SeriesData
+	for rows.Next() {
+		var s SeriesData
+		if err := rows.Scan(
+			&s.ID,
+			&s.Name,
+			&s.CoverPath,
+			&s.Books,
+			&s.Finished,
+			&s.Progress,
+			&s.AddedTime,
+			&s.LastOpened,
+		); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+func GetSeriesByID(db *sql.DB, id int64) (*SeriesData, error) {
+	var s SeriesData
+	err := db.QueryRow(`SELECT id, name FROM series WHERE id = ?`, id).Scan(&s.ID, &s.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func GetBooksInSeries(db *sql.DB, id int64, pathPrefix string) ([]BookData, error) {
+	query := fmt.Sprintf(`
+		SELECT path, cover_path, type, title, added_time, last_modded, last_opened,
+		       current_position, progress, series_number
+		FROM books
+		WHERE series_id = ? AND path LIKE ? AND missing_since = 0
+		ORDER BY %s`, seriesOrder)
+
+	rows, err := db.Query(query, id, pathPrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []BookData
+	for rows.Next() {
+		var b BookData
+		if err := rows.Scan(
+			&b.Path,
+			&b.CoverPath,
+			&b.Type,
+			&b.Title,
+			&b.AddedTime,
+			&b.LastModded,
+			&b.LastOpened,
+			&b.CurrentPosition,
+			&b.Progress,
+			&b.SeriesNumber,
+		); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func createPDFTOCTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS pdf_toc (
+			path TEXT PRIMARY KEY,
+			mod_time INTEGER NOT NULL,
+			toc TEXT NOT NULL
+		);
+	`)
+	return err
+}
+
+// GetPDFTOC returns the stored table of contents of a PDF as JSON, and the
+// modification time it was read at. ok is false when it has not been read.
+func GetPDFTOC(db *sql.DB, path string) (toc []byte, modTime int64, ok bool, err error) {
+	var data string
+	err = db.QueryRow(`SELECT mod_time, toc FROM pdf_toc WHERE path = ?`, path).Scan(&modTime, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return []byte(data), modTime, true, nil
+}
+
+func SetPDFTOC(db Execer, path string, modTime int64, toc []byte) error {
+	_, err := db.Exec(`
+		INSERT INTO pdf_toc (path, mod_time, toc) VALUES (?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET mod_time = excluded.mod_time, toc = excluded.toc
+	`, path, modTime, string(toc))
+	return err
+}
+
+func DeletePDFTOC(db Execer, path string) error {
+	_, err := db.Exec(`DELETE FROM pdf_toc WHERE path = ?`, path)
+	return err
+}
+
+func movePDFTOC(db Execer, oldPath, newPath string) error {
+	_, err := db.Exec(`UPDATE pdf_toc SET path = ? WHERE path = ?`, newPath, oldPath)
+	return err
+}
@@ -0,0 +1,107 @@
+// Package archive reads the entries of zip based books (CBZ and EPUB)
+// without extracting them or shelling out to 7z.
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Reader is an open archive. Entries can be read any number of times until
+// Close is called.
+type Reader struct {
+	zr      *zip.ReadCloser
+	entries map[string]*zip.File
+	names   []string
+}
+
+func Open(archivePath string) (*Reader, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	r := &Reader{zr: zr, entries: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if _, ok := r.entries[f.Name]; ok {
+			// the zip format allows duplicates; readers use the first one
+			continue
+		}
+		r.entries[f.Name] = f
+		r.names = append(r.names, f.Name)
+	}
+
+	return r, nil
+}
+
+func (r *Reader) Close() error {
+	return r.zr.Close()
+}
+
+// Names returns the exact names of the files in the archive, in archive order.
+func (r *Reader) Names() []string {
+	return r.names
+}
+
+// Comment returns the archive comment, where ComicBookLover keeps its metadata.
+func (r *Reader) Comment() string {
+	return r.zr.Comment
+}
+
+func (r *Reader) Has(name string) bool {
+	_, ok := r.entries[name]
+	return ok
+}
+
+// Open streams a single entry.
+func (r *Reader) Open(name string) (io.ReadCloser, error) {
+	f, ok := r.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in archive", name)
+	}
+	return f.Open()
+}
+
+func (r *Reader) ReadFile(name string) ([]byte, error) {
+	rc, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Images returns the image entries in page order.
+func (r *Reader) Images() []string {
+	var images []string
+	for _, name := range r.names {
+		if IsImage(name) {
+			images = append(images, name)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+func IsImage(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".webp", ".bmp":
+		return true
+	}
+	return false
+}
+
+// Size returns the uncompressed size of an entry.
+func (r *Reader) Size(name string) int64 {
+	if f, ok := r.entries[name]; ok {
+		return int64(f.UncompressedSize64)
+	}
+	return 0
+}
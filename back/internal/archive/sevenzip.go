@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Entry is a file in an archive listed by 7z.
+type Entry struct {
+	Name string
+	Size int64
+}
+
+// List7z returns the files in an archive and the archive comment, using the
+// technical listing of 7z so that names with spaces are kept intact. It is
+// used for RAR, which the standard library cannot read.
+func List7z(archivePath string) ([]Entry, string, error) {
+	out, err := exec.Command("7z", "l", "-slt", archivePath).Output()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var entries []Entry
+	var comment strings.Builder
+	inComment := false
+	inEntries := false
+
+	var entry Entry
+	isDir := false
+	flush := func() {
+		if entry.Name != "" && !isDir {
+			entries = append(entries, entry)
+		}
+		entry = Entry{}
+		isDir = false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !inEntries {
+			// archive properties come first and end with a line of dashes
+			switch {
+			case strings.HasPrefix(line, "----------"):
+				inEntries = true
+				inComment = false
+			case strings.HasPrefix(line, "Comment = "):
+				comment.WriteString(strings.TrimPrefix(line, "Comment = "))
+				inComment = true
+			case inComment:
+				comment.WriteString("\n" + line)
+			}
+			continue
+		}
+
+		// each entry is a block of "Key = value" lines
+		switch {
+		case strings.HasPrefix(line, "Path = "):
+			flush()
+			entry.Name = strings.TrimPrefix(line, "Path = ")
+		case strings.HasPrefix(line, "Size = "):
+			entry.Size, _ = strconv.ParseInt(strings.TrimPrefix(line, "Size = "), 10, 64)
+		case line == "Folder = +" || strings.HasPrefix(line, "Attributes = D"):
+			isDir = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", err
+	}
+	flush()
+
+	return entries, comment.String(), nil
+}
+
+// Extract7z extracts a single file from the archive using 7z and returns its contents.
+func Extract7z(archivePath, name string) ([]byte, error) {
+	return exec.Command("7z", "x", "-so", archivePath, name).Output()
+}
+
+// Open7z starts 7z extracting a single file and returns a reader of its
+// contents. Closing it stops 7z, so a caller can stop after a header.
+func Open7z(archivePath, name string) (io.ReadCloser, error) {
+	cmd := exec.Command("7z", "x", "-so", archivePath, name)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &extraction{stdout, cmd}, nil
+}
+
+type extraction struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (e *extraction) Close() error {
+	e.ReadCloser.Close()
+	e.cmd.Process.Kill()
+	e.cmd.Wait()
+	return nil
+}
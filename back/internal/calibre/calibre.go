@@ -0,0 +1,105 @@
+package calibre
+
+import (
+	"back/internal/meta"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DBFile     = "metadata.db"
+	OPFFile    = "metadata.opf"
+	CoverFile  = "cover.jpg"
+	libraryDir = 2
+)
+
+// LibraryRoot returns the Calibre library a book belongs to. Calibre stores
+// each book as <library>/<author>/<title (id)>/<file>, with metadata.db at
+// the top of the library.
+func LibraryRoot(bookPath string) (string, bool) {
+	root := filepath.Dir(bookPath)
+	for i := 0; i < libraryDir; i++ {
+		root = filepath.Dir(root)
+	}
+
+	info, err := os.Stat(filepath.Join(root, DBFile))
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return root, true
+}
+
+// IsSidecar reports whether path is a file Calibre keeps next to a book, whose
+// change should rescan the book.
+func IsSidecar(path string) bool {
+	name := filepath.Base(path)
+	if name != OPFFile && name != CoverFile {
+		return false
+	}
+	_, ok := LibraryRoot(path)
+	return ok
+}
+
+// ModTime returns the modification time of a Calibre book, which includes
+// metadata.opf and cover.jpg since Calibre edits those instead of the book.
+// Other books keep their own modification time.
+func ModTime(bookPath string, modTime int64) int64 {
+	if _, ok := LibraryRoot(bookPath); !ok {
+		return modTime
+	}
+
+	dir := filepath.Dir(bookPath)
+	for _, name := range []string{OPFFile, CoverFile} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			modTime = max(modTime, info.ModTime().Unix())
+		}
+	}
+	return modTime
+}
+
+// Cover returns the cover.jpg of a Calibre book, or "" when there is none.
+func Cover(bookPath string) string {
+	if _, ok := LibraryRoot(bookPath); !ok {
+		return ""
+	}
+
+	coverPath := filepath.Join(filepath.Dir(bookPath), CoverFile)
+	if _, err := os.Stat(coverPath); err != nil {
+		return ""
+	}
+	return coverPath
+}
+
+// Read returns the metadata Calibre keeps for a book, from metadata.db or,
+// when the book is not found there, from its metadata.opf.
+func Read(bookPath string) (meta.Meta, error) {
+	root, ok := LibraryRoot(bookPath)
+	if !ok {
+		return meta.Meta{}, fmt.Errorf("not in a Calibre library: %s", bookPath)
+	}
+
+	info, err := os.Stat(bookPath)
+	if err != nil {
+		return meta.Meta{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	m, err := readDB(root, bookPath)
+	if err != nil {
+		data, opfErr := os.ReadFile(filepath.Join(filepath.Dir(bookPath), OPFFile))
+		if opfErr != nil {
+			return meta.Meta{}, fmt.Errorf("failed to read Calibre metadata: %w", err)
+		}
+		if m, err = meta.ParseOPF(data); err != nil {
+			return meta.Meta{}, fmt.Errorf("failed to parse %s: %w", OPFFile, err)
+		}
+	}
+
+	if m.Title == "" {
+		m.Title = strings.TrimSuffix(filepath.Base(bookPath), filepath.Ext(bookPath))
+	}
+	m.ModTime = ModTime(bookPath, info.ModTime().Unix())
+
+	return meta.Normalise(m, bookPath), nil
+}
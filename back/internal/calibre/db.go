@@ -0,0 +1,193 @@
+package calibre
+
+import (
+	"back/internal/meta"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	dbMu sync.Mutex
+	dbs  = make(map[string]*sql.DB)
+)
+
+// openDB returns a connection to the metadata.db of a library. Connections
+// are kept open for the lifetime of the server.
+func openDB(root string) (*sql.DB, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if db, ok := dbs[root]; ok {
+		return db, nil
+	}
+
+	// query_only makes sure the Calibre library is never modified
+	db, err := sql.Open("sqlite", filepath.Join(root, DBFile)+"?_pragma=query_only(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	dbs[root] = db
+	return db, nil
+}
+
+func readDB(root, bookPath string) (meta.Meta, error) {
+	db, err := openDB(root)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+
+	rel, err := filepath.Rel(root, filepath.Dir(bookPath))
+	if err != nil {
+		return meta.Meta{}, err
+	}
+
+	var id int64
+	var m meta.Meta
+	var seriesIndex float64
+	var pubdate string
+	err = db.QueryRow(`
+		SELECT id, title, COALESCE(series_index, 1), COALESCE(pubdate, '')
+		FROM books
+		WHERE path = ?`, filepath.ToSlash(rel)).Scan(&id, &m.Title, &seriesIndex, &pubdate)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT a.name, COALESCE(a.sort, '')
+		FROM authors a
+		JOIN books_authors_link l ON l.author = a.id
+		WHERE l.book = ?
+		ORDER BY l.id`, id)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+	for rows.Next() {
+		c := meta.Contributor{Role: meta.RoleAuthor}
+		if err := rows.Scan(&c.Name, &c.SortName); err != nil {
+			rows.Close()
+			return meta.Meta{}, err
+		}
+		// Calibre stores "Last, First" names with a pipe
+		c.Name = strings.ReplaceAll(c.Name, "|", ",")
+		c.SortNameGiven = c.SortName != ""
+		m.Contributors = append(m.Contributors, c)
+		m.Keywords = append(m.Keywords, c.Name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return meta.Meta{}, err
+	}
+
+	tags, err := queryStrings(db, `
+		SELECT t.name FROM tags t
+		JOIN books_tags_link l ON l.tag = t.id
+		WHERE l.book = ?
+		ORDER BY t.name`, id)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+	m.Keywords = append(m.Keywords, tags...)
+
+	if m.Series, err = queryString(db, `
+		SELECT s.name FROM series s
+		JOIN books_series_link l ON l.series = s.id
+		WHERE l.book = ?`, id); err != nil {
+		return meta.Meta{}, err
+	}
+	if m.Series != "" {
+		m.SeriesNumber = strconv.FormatFloat(seriesIndex, 'f', -1, 64)
+		m.Keywords = append(m.Keywords, m.Series)
+	}
+
+	if m.Publisher, err = queryString(db, `
+		SELECT p.name FROM publishers p
+		JOIN books_publishers_link l ON l.publisher = p.id
+		WHERE l.book = ?`, id); err != nil {
+		return meta.Meta{}, err
+	}
+	if m.Publisher != "" {
+		m.Keywords = append(m.Keywords, m.Publisher)
+	}
+
+	if m.Language, err = queryString(db, `
+		SELECT g.lang_code FROM languages g
+		JOIN books_languages_link l ON l.lang_code = g.id
+		WHERE l.book = ?
+		ORDER BY l.item_order`, id); err != nil {
+		return meta.Meta{}, err
+	}
+
+	if m.Description, err = queryString(db, `SELECT text FROM comments WHERE book = ?`, id); err != nil {
+		return meta.Meta{}, err
+	}
+
+	rating, err := queryString(db, `
+		SELECT r.rating FROM ratings r
+		JOIN books_ratings_link l ON l.rating = r.id
+		WHERE l.book = ?`, id)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+	if r, err := strconv.ParseFloat(rating, 64); err == nil {
+		// Calibre rates from 0 to 10, two points per star
+		m.Rating = r / 2
+	}
+
+	rows, err = db.Query(`SELECT type, val FROM identifiers WHERE book = ? ORDER BY type`, id)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+	for rows.Next() {
+		var id meta.Identifier
+		if err := rows.Scan(&id.Scheme, &id.Value); err != nil {
+			rows.Close()
+			return meta.Meta{}, err
+		}
+		m.Identifiers = append(m.Identifiers, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return meta.Meta{}, err
+	}
+
+	// Calibre uses the year 101 for an unknown date
+	if len(pubdate) >= 10 && !strings.HasPrefix(pubdate, "0101") {
+		m.Published = pubdate[:10]
+	}
+
+	return m, nil
+}
+
+// queryString returns the first column of the first row, or "" when there
+// is no row.
+func queryString(db *sql.DB, query string, args ...any) (string, error) {
+	var s sql.NullString
+	err := db.QueryRow(query, args...).Scan(&s)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return s.String, err
+}
+
+func queryStrings(db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Library struct {
+	Name      string   `yaml:"name"`
+	Root      string   `yaml:"root"`
+	Direction string   `yaml:"direction"`
+	Formats   []string `yaml:"formats"`
+}
+
+type Config struct {
+	Libraries      []Library `yaml:"libraries"`
+	Ignore         []string  `yaml:"ignore"`
+	IncludeHidden  bool      `yaml:"include_hidden"`
+	FollowSymlinks bool      `yaml:"follow_symlinks"`
+}
+
+// defaultIgnore covers the metadata folders NAS systems and operating
+// systems leave next to the books.
+var defaultIgnore = []string{
+	"@eaDir/",
+	"#recycle/",
+	"#snapshot/",
+	"$RECYCLE.BIN/",
+	"System Volume Information/",
+	"lost+found/",
+}
+
+var path string
+
+func init() {
+	path = os.Getenv("CONFIG_PATH")
+	if path == "" {
+		path = "/config/config.yml"
+	}
+}
+
+// Load reads the config file. Without a config file, everything under /books
+// is served as a single library.
+func Load() (Config, error) {
+	cfg := Config{}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+
+	if cfg.Ignore == nil {
+		cfg.Ignore = defaultIgnore
+	}
+
+	if len(cfg.Libraries) == 0 {
+		cfg.Libraries = []Library{{Name: "Books", Root: "/books"}}
+	}
+
+	return cfg, nil
+}
@@ -1,19 +1,16 @@
 package cover
 
 import (
+	"back/internal/imaging"
 	"bufio"
 	"bytes"
 	"fmt"
-	"image"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
-
-	"github.com/chai2010/webp"
-	"golang.org/x/image/draw"
 )
 
 func extractCBRCover(cbrPath, outputWebPPath string) error {
@@ -79,29 +76,13 @@ func extractCBRCover(cbrPath, outputWebPPath string) error {
 	}
 
 	// 5. Decode image
-	img, err := decodeImageWithWebP(imgData)
+	img, err := imaging.Decode(imgData)
 	if err != nil {
 		return fmt.Errorf("failed to decode image data: %w", err)
 	}
 
 	// 6. Resize if necessary
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-	short := width
-	if height < width {
-		short = height
-	}
-
-	var resized image.Image = img
-	if short > size {
-		scale := float64(size) / float64(short)
-		newW := int(float64(width) * scale)
-		newH := int(float64(height) * scale)
-		dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
-		draw.ApproxBiLinear.Scale(dst, dst.Rect, img, bounds, draw.Over, nil)
-		resized = dst
-	}
+	resized := imaging.Thumbnail(img, size)
 
 	// 7. Encode to WebP
 	outFile, err := os.Create(outputWebPPath)
@@ -111,7 +92,7 @@ func extractCBRCover(cbrPath, outputWebPPath string) error {
 	defer outFile.Close()
 
 	var buf bytes.Buffer
-	if err := webp.Encode(&buf, resized, &webp.Options{Lossless: false, Quality: float32(quality)}); err != nil {
+	if err := imaging.Encode(&buf, resized, imaging.WebP, quality); err != nil {
 		return fmt.Errorf("failed to encode WebP: %w", err)
 	}
 	if _, err := buf.WriteTo(outFile); err != nil {
@@ -25,6 +25,19 @@ func getEnvInt(key string, def int) int {
 	return def
 }
 
+// versions are stored with every book and increased whenever the cover of a
+// format is chosen differently, so that covers extracted by older versions
+// are extracted again.
+var versions = map[string]int{
+	// covers are taken from the OPF manifest instead of the first image
+	"EPUB": 1,
+}
+
+// Version returns the version of the cover extraction for bookType.
+func Version(bookType string) int {
+	return versions[bookType]
+}
+
 func ExtractCover(inputPath, outputPath, bookType string) error {
 	switch bookType {
 	case "PDF":
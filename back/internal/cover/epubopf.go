@@ -0,0 +1,192 @@
+package cover
+
+import (
+	"back/internal/archive"
+	"encoding/xml"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type opfPackage struct {
+	Metas []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+	Guide []struct {
+		Type string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"guide>reference"`
+}
+
+// opfCover returns the archive path of the cover image declared by the OPF
+// package document, or "" when the book does not declare one. References to
+// files missing from the archive are skipped.
+//
+// The declarations are tried in order of reliability: the EPUB 3 cover-image
+// property, the EPUB 2 cover meta, the guide cover reference, and finally
+// the first image on the first page of the spine.
+func opfCover(ar *archive.Reader) string {
+	opfPath := epubRootfile(ar)
+	if opfPath == "" {
+		return ""
+	}
+	data, err := ar.ReadFile(opfPath)
+	if err != nil {
+		return ""
+	}
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return ""
+	}
+	opfDir := path.Dir(opfPath)
+
+	image := func(href string) string {
+		p := resolveHref(opfDir, href)
+		if p != "" && ar.Has(p) && archive.IsImage(p) {
+			return p
+		}
+		return ""
+	}
+
+	for _, item := range pkg.Items {
+		if hasProperty(item.Properties, "cover-image") {
+			if p := image(item.Href); p != "" {
+				return p
+			}
+		}
+	}
+
+	for _, meta := range pkg.Metas {
+		if meta.Name != "cover" {
+			continue
+		}
+		for _, item := range pkg.Items {
+			if item.ID == meta.Content {
+				if p := image(item.Href); p != "" {
+					return p
+				}
+			}
+		}
+		// some books put the path in content instead of the item id
+		if p := image(meta.Content); p != "" {
+			return p
+		}
+	}
+
+	for _, ref := range pkg.Guide {
+		if !strings.EqualFold(ref.Type, "cover") {
+			continue
+		}
+		if p := image(ref.Href); p != "" {
+			return p
+		}
+		if p := pageImage(ar, resolveHref(opfDir, ref.Href)); p != "" {
+			return p
+		}
+	}
+
+	if len(pkg.Spine) > 0 {
+		for _, item := range pkg.Items {
+			if item.ID == pkg.Spine[0].IDRef {
+				return pageImage(ar, resolveHref(opfDir, item.Href))
+			}
+		}
+	}
+
+	return ""
+}
+
+// epubRootfile returns the path of the OPF package document from
+// META-INF/container.xml.
+func epubRootfile(ar *archive.Reader) string {
+	data, err := ar.ReadFile("META-INF/container.xml")
+	if err != nil {
+		return ""
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(data, &container); err != nil || len(container.Rootfiles) == 0 {
+		return ""
+	}
+	return container.Rootfiles[0].FullPath
+}
+
+// pageImage returns the first image referenced by an XHTML page, either
+// through <img src> or the SVG <image xlink:href> that cover pages often use.
+func pageImage(ar *archive.Reader, pagePath string) string {
+	if pagePath == "" || !ar.Has(pagePath) {
+		return ""
+	}
+	data, err := ar.ReadFile(pagePath)
+	if err != nil {
+		return ""
+	}
+
+	// pages are not always well-formed XML, so parse them leniently
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	pageDir := path.Dir(pagePath)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var href string
+		for _, attr := range start.Attr {
+			switch {
+			case strings.EqualFold(start.Name.Local, "img") && attr.Name.Local == "src":
+				href = attr.Value
+			case strings.EqualFold(start.Name.Local, "image") && attr.Name.Local == "href":
+				href = attr.Value
+			}
+		}
+		if href == "" {
+			continue
+		}
+		if p := resolveHref(pageDir, href); p != "" && ar.Has(p) && archive.IsImage(p) {
+			return p
+		}
+	}
+}
+
+// resolveHref turns a reference relative to dir into an archive path.
+func resolveHref(dir, href string) string {
+	href, _, _ = strings.Cut(href, "#")
+	if href == "" || strings.Contains(href, "://") {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return strings.TrimPrefix(path.Join(dir, href), "/")
+}
+
+func hasProperty(properties, property string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
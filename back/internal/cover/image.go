@@ -0,0 +1,40 @@
+package cover
+
+import (
+	"back/internal/imaging"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FromImage converts an existing cover image, such as the cover.jpg of a
+// Calibre library, instead of extracting one from the book.
+func FromImage(imagePath, outputWebPPath string) error {
+	outDir := filepath.Dir(outputWebPPath)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	imgData, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read cover image: %w", err)
+	}
+
+	img, err := imaging.Decode(imgData)
+	if err != nil {
+		return fmt.Errorf("failed to decode image data: %w", err)
+	}
+
+	resized := imaging.Thumbnail(img, size)
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, resized, imaging.WebP, quality); err != nil {
+		return fmt.Errorf("failed to encode WebP: %w", err)
+	}
+	if err := os.WriteFile(outputWebPPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write WebP file: %w", err)
+	}
+
+	return nil
+}
@@ -1,16 +1,13 @@
 package cover
 
 import (
+	"back/internal/imaging"
 	"bytes"
 	"fmt"
-	"image"
 	"image/png"
 	"os"
 	"os/exec"
 	"path/filepath"
-
-	"github.com/chai2010/webp"
-	"golang.org/x/image/draw"
 )
 
 func extractPDFCover(pdfPath, outputWebPPath string) error {
@@ -30,8 +27,8 @@ func extractPDFCover(pdfPath, outputWebPPath string) error {
 		"-o", tmpPNG,
 		pdfPath,
 	)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("ghostscript failed: %v\noutput: %s", err, out)
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("ghostscript failed: %w", err)
 	}
 
 	// Step 2: open and decode the PNG image
@@ -47,23 +44,7 @@ func extractPDFCover(pdfPath, outputWebPPath string) error {
 	}
 
 	// Step 3: resize if needed (short side ≥ size)
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-	short := width
-	if height < width {
-		short = height
-	}
-
-	var resized image.Image = img
-	if short > size {
-		scale := float64(size) / float64(short)
-		newW := int(float64(width) * scale)
-		newH := int(float64(height) * scale)
-		dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
-		draw.ApproxBiLinear.Scale(dst, dst.Rect, img, bounds, draw.Over, nil)
-		resized = dst
-	}
+	resized := imaging.Thumbnail(img, size)
 
 	// Step 4: save as WebP
 	outFile, err := os.Create(outputWebPPath)
@@ -73,7 +54,7 @@ func extractPDFCover(pdfPath, outputWebPPath string) error {
 	defer outFile.Close()
 
 	var buf bytes.Buffer
-	if err := webp.Encode(&buf, resized, &webp.Options{Lossless: false, Quality: float32(quality)}); err != nil {
+	if err := imaging.Encode(&buf, resized, imaging.WebP, quality); err != nil {
 		return fmt.Errorf("failed to encode WebP: %w", err)
 	}
 	if _, err := buf.WriteTo(outFile); err != nil {
@@ -2,37 +2,115 @@ package diff
 
 import (
 	"back/database"
+	"back/internal/calibre"
+	"back/internal/ignore"
+	"back/internal/library"
+	"back/internal/sidecar"
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 )
 
 type DiffResult struct {
-	Added   []string
-	Updated []string
-	Deleted []string
+	Added    []string
+	Updated  []string
+	Deleted  []string
+	Moved    []Move
+	Restored []string
+	// Missing lists books that were already marked missing and are still
+	// absent. They need no action but can still be matched as moves.
+	Missing []string
 }
 
-func Diff(db *sql.DB) (DiffResult, error) {
-	dbFiles, err := database.GetPathAndLastModdedList(db)
-	if err != nil {
-		return DiffResult{}, err
+// Diff compares the books on disk below each root with the books stored
+// below it in the database. Books outside the roots are left alone.
+func Diff(db *sql.DB, roots []string) (DiffResult, error) {
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return DiffResult{}, err
+		}
+		if !info.IsDir() {
+			return DiffResult{}, fmt.Errorf("not a directory: %s", root)
+		}
 	}
 
-	fsFiles, err := listFilesWithModTime("/books/")
+	return DiffPaths(db, roots)
+}
+
+// Orphans returns the books whose path no longer belongs to any library, e.g.
+// after a library has been removed from the config. Books already marked
+// missing are not included.
+func Orphans(db *sql.DB) ([]string, error) {
+	dbFiles, err := database.GetPathAndLastModdedListByPrefix(db, "/")
 	if err != nil {
-		return DiffResult{}, err
+		return nil, err
 	}
 
-	dbMap := make(map[string]int64)
+	var orphans []string
 	for _, f := range dbFiles {
-		dbMap[f.Path] = f.LastModded
+		if f.MissingSince == 0 && library.ForPath(f.Path) == nil {
+			orphans = append(orphans, f.Path)
+		}
 	}
 
+	return orphans, nil
+}
+
+// DiffPaths compares only the given files or directories against the database.
+// A path that no longer exists reports every book stored at or below it as deleted.
+func DiffPaths(db *sql.DB, paths []string) (DiffResult, error) {
+	dbMap := make(map[string]database.PathModded)
 	fsMap := make(map[string]int64)
-	for _, f := range fsFiles {
-		fsMap[f.Path] = f.LastModded
+
+	for _, path := range paths {
+		// a changed ignore file can hide or reveal anything next to it, and
+		// Calibre edits metadata.opf and cover.jpg next to the book
+		if filepath.Base(path) == ignore.FileName || calibre.IsSidecar(path) {
+			path = filepath.Dir(path)
+		} else if book, ok := sidecar.BookFor(path); ok {
+			path = book
+		}
+
+		dbFiles, err := database.GetPathAndLastModdedListByPrefix(db, path)
+		if err != nil {
+			return DiffResult{}, err
+		}
+		for _, f := range dbFiles {
+			dbMap[f.Path] = f
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return DiffResult{}, err
+		}
+
+		if ignore.Ignored(path, info.IsDir()) {
+			continue
+		}
+
+		if info.IsDir() {
+			fsFiles, err := listFilesWithModTime(path)
+			if err != nil {
+				return DiffResult{}, err
+			}
+			for _, f := range fsFiles {
+				fsMap[f.Path] = f.LastModded
+			}
+		} else if isBookFile(path) {
+			fsMap[path] = ModTime(path, info.ModTime().Unix())
+		}
 	}
 
-	var added, updated, deleted []string
+	return detectMoves(db, compare(dbMap, fsMap))
+}
+
+func compare(dbMap map[string]database.PathModded, fsMap map[string]int64) DiffResult {
+	var added, updated, deleted, restored, missing []string
 
 	for path := range fsMap {
 		if _, ok := dbMap[path]; !ok {
@@ -40,21 +118,34 @@ func Diff(db *sql.DB) (DiffResult, error) {
 		}
 	}
 
-	for path := range dbMap {
+	for path, dbFile := range dbMap {
 		if _, ok := fsMap[path]; !ok {
-			deleted = append(deleted, path)
+			if dbFile.MissingSince == 0 {
+				deleted = append(deleted, path)
+			} else {
+				missing = append(missing, path)
+			}
 		}
 	}
 
-	for path, dbMod := range dbMap {
-		if fsMod, ok := fsMap[path]; ok && fsMod != dbMod {
+	for path, dbFile := range dbMap {
+		fsMod, ok := fsMap[path]
+		if !ok {
+			continue
+		}
+		if dbFile.MissingSince != 0 {
+			restored = append(restored, path)
+		}
+		if fsMod != dbFile.LastModded {
 			updated = append(updated, path)
 		}
 	}
 
 	return DiffResult{
-		Added:   added,
-		Updated: updated,
-		Deleted: deleted,
-	}, nil
+		Added:    added,
+		Updated:  updated,
+		Deleted:  deleted,
+		Restored: restored,
+		Missing:  missing,
+	}
 }
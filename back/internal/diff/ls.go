@@ -1,6 +1,10 @@
 package diff
 
 import (
+	"back/internal/calibre"
+	"back/internal/ignore"
+	"back/internal/library"
+	"back/internal/sidecar"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,26 +15,38 @@ type FileInfo struct {
 	LastModded int64
 }
 
-func listFilesWithModTime(root string) ([]FileInfo, error) {
-	var files []FileInfo
+var allowedExt = map[string]bool{
+	".epub": true,
+	".pdf":  true,
+	".cbz":  true,
+	".cbr":  true,
+}
 
-	allowedExt := map[string]bool{
-		".epub": true,
-		".pdf":  true,
-		".cbz":  true,
-		".cbr":  true,
+func isBookFile(path string) bool {
+	if !allowedExt[strings.ToLower(filepath.Ext(path))] {
+		return false
 	}
+	lib := library.ForPath(path)
+	return lib != nil && lib.Allows(path)
+}
 
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// ModTime returns the modification time of a book including the files next
+// to it that provide its metadata. The scanner stores the same value, so that
+// changing any of them rescans the book.
+func ModTime(path string, modTime int64) int64 {
+	modTime = calibre.ModTime(path, modTime)
+	return sidecar.ModTime(path, modTime)
+}
+
+func listFilesWithModTime(root string) ([]FileInfo, error) {
+	var files []FileInfo
+
+	err := ignore.Walk(root, func(path string, info os.FileInfo) error {
 		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if allowedExt[ext] {
+			if isBookFile(path) {
 				files = append(files, FileInfo{
 					Path:       path,
-					LastModded: info.ModTime().Unix(),
+					LastModded: ModTime(path, info.ModTime().Unix()),
 				})
 			}
 		}
@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"back/database"
+	"back/internal/fingerprint"
+	"database/sql"
+	"os"
+)
+
+type Move struct {
+	From string
+	To   string
+}
+
+// detectMoves pairs deleted or missing books with added files that have the
+// same fingerprint, so a moved or renamed book keeps its row instead of being
+// deleted and added again. Only added files whose size matches a deleted book
+// are hashed.
+func detectMoves(db *sql.DB, result DiffResult) (DiffResult, error) {
+	if len(result.Added) == 0 || len(result.Deleted)+len(result.Missing) == 0 {
+		return result, nil
+	}
+
+	gone := append(append([]string{}, result.Deleted...), result.Missing...)
+
+	bySize := make(map[int64][]string)
+	fingerprints := make(map[string]string)
+	for _, path := range gone {
+		book, err := database.GetBookByPath(db, path)
+		if err != nil {
+			return DiffResult{}, err
+		}
+		if book.Fingerprint == "" {
+			continue
+		}
+		bySize[book.Size] = append(bySize[book.Size], path)
+		fingerprints[path] = book.Fingerprint
+	}
+
+	moved := make(map[string]bool)
+	var added []string
+
+	for _, path := range result.Added {
+		info, err := os.Stat(path)
+		if err != nil || len(bySize[info.Size()]) == 0 {
+			added = append(added, path)
+			continue
+		}
+
+		size, fp, err := fingerprint.Compute(path)
+		if err != nil {
+			added = append(added, path)
+			continue
+		}
+
+		candidates := bySize[size]
+		match := -1
+		for i, from := range candidates {
+			if fingerprints[from] == fp {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			added = append(added, path)
+			continue
+		}
+
+		from := candidates[match]
+		bySize[size] = append(candidates[:match:match], candidates[match+1:]...)
+		moved[from] = true
+		result.Moved = append(result.Moved, Move{From: from, To: path})
+	}
+
+	result.Added = added
+	result.Deleted = withoutMoved(result.Deleted, moved)
+	result.Missing = withoutMoved(result.Missing, moved)
+
+	return result, nil
+}
+
+func withoutMoved(paths []string, moved map[string]bool) []string {
+	var remaining []string
+	for _, path := range paths {
+		if !moved[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,148 @@
+package duplicate
+
+import (
+	"back/database"
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Report struct {
+	// Exact groups books whose files have identical content.
+	Exact [][]database.BookData
+	// Likely groups books with the same normalised title and author, e.g. the
+	// EPUB and the PDF edition of the same book.
+	Likely [][]database.BookData
+	// Pending counts the books that may be exact duplicates but have not
+	// been hashed by the scanner yet.
+	Pending int
+}
+
+func Find(bookDB *sql.DB) (Report, error) {
+	books, err := database.GetBooksForDuplicates(bookDB)
+	if err != nil {
+		return Report{}, err
+	}
+
+	exact, pending := findExact(books)
+	return Report{
+		Exact:   exact,
+		Likely:  findLikely(books),
+		Pending: pending,
+	}, nil
+}
+
+// Unhashed returns the books that share their fingerprint with another book
+// but whose full content has not been hashed yet. The scanner hashes them,
+// so that reports never have to read whole files.
+func Unhashed(bookDB *sql.DB) ([]string, error) {
+	books, err := database.GetBooksForDuplicates(bookDB)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, group := range candidates(books) {
+		for _, b := range group {
+			if b.ContentHash == "" {
+				paths = append(paths, b.Path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// candidates groups books by size and fingerprint, which the scanner already
+// stored. Only these groups can contain books with identical content.
+func candidates(books []database.BookData) [][]database.BookData {
+	byFingerprint := make(map[string][]database.BookData)
+	for _, b := range books {
+		if b.Fingerprint == "" {
+			continue
+		}
+		byFingerprint[b.Fingerprint] = append(byFingerprint[b.Fingerprint], b)
+	}
+	return groups(byFingerprint)
+}
+
+// findExact groups the candidates by the hash of their full content. It
+// also returns the number of candidates that have no hash yet.
+func findExact(books []database.BookData) ([][]database.BookData, int) {
+	byHash := make(map[string][]database.BookData)
+	pending := 0
+	for _, group := range candidates(books) {
+		for _, b := range group {
+			if b.ContentHash == "" {
+				pending++
+				continue
+			}
+			byHash[b.ContentHash] = append(byHash[b.ContentHash], b)
+		}
+	}
+
+	return groups(byHash), pending
+}
+
+func findLikely(books []database.BookData) [][]database.BookData {
+	byKey := make(map[string][]database.BookData)
+	for _, b := range books {
+		// without an author, equal titles are mostly volume names such as
+		// "Chapter 1", so only books with metadata are compared
+		title := normalise(b.Title)
+		author := normaliseAuthor(b.Author)
+		if title == "" || author == "" {
+			continue
+		}
+		key := title + "\x00" + author
+		byKey[key] = append(byKey[key], b)
+	}
+
+	return groups(byKey)
+}
+
+func groups(m map[string][]database.BookData) [][]database.BookData {
+	var result [][]database.BookData
+	for _, group := range m {
+		if len(group) > 1 {
+			result = append(result, group)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0].Path < result[j][0].Path
+	})
+
+	return result
+}
+
+// normalise lowercases a title and drops bracketed notes such as "(Retail)"
+// or "[EPUB]", punctuation and repeated whitespace.
+func normalise(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// normaliseAuthor also sorts the name parts, so that "Tolkien, J. R. R." and
+// "J. R. R. Tolkien" are considered the same author.
+func normaliseAuthor(s string) string {
+	parts := strings.Fields(normalise(s))
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
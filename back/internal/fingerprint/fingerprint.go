@@ -0,0 +1,68 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// chunkSize is how much of the head and the tail of a file is hashed.
+const chunkSize = 64 * 1024
+
+// Compute returns the size of the file and a hash of its size, first and last
+// 64 KiB. It is cheap enough to run on every book and stable across moves and
+// renames, which is all the scanner needs to recognise a book at a new path.
+func Compute(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	size := info.Size()
+
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, size)
+
+	if _, err := io.CopyN(h, f, chunkSize); err != nil && err != io.EOF {
+		return 0, "", err
+	}
+
+	if size > 2*chunkSize {
+		if _, err := f.Seek(-chunkSize, io.SeekEnd); err != nil {
+			return 0, "", err
+		}
+		if _, err := io.CopyN(h, f, chunkSize); err != nil && err != io.EOF {
+			return 0, "", err
+		}
+	} else if size > chunkSize {
+		if _, err := io.Copy(h, f); err != nil {
+			return 0, "", err
+		}
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ContentHash returns the SHA-256 of the whole file. It is only computed for
+// books whose fingerprints already match, to confirm they are identical.
+func ContentHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,178 @@
+package ignore
+
+import (
+	"back/internal/library"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the per-directory ignore file.
+const FileName = ".shelfignore"
+
+var (
+	global         []string
+	includeHidden  bool
+	followSymlinks bool
+)
+
+// Init sets the ignore patterns that apply to every library, as if they were
+// listed in a .shelfignore at each library root.
+func Init(patterns []string, hidden, symlinks bool) {
+	global = patterns
+	includeHidden = hidden
+	followSymlinks = symlinks
+}
+
+// Matcher evaluates the ignore rules in effect inside a directory: the global
+// patterns followed by every .shelfignore from the library root down. Later
+// rules take precedence, so a deeper .shelfignore can re-include a file.
+type Matcher struct {
+	sets []*ruleSet
+}
+
+// enter returns the matcher for dir, adding the rules of its .shelfignore.
+func (m Matcher) enter(dir string) Matcher {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return m
+	}
+
+	sets := make([]*ruleSet, len(m.sets), len(m.sets)+1)
+	copy(sets, m.sets)
+	return Matcher{sets: append(sets, parse(dir, data))}
+}
+
+func (m Matcher) ignored(path string, isDir bool) bool {
+	if !includeHidden && strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+
+	ignored := false
+	for _, set := range m.sets {
+		if matched, ign := set.match(path, isDir); matched {
+			ignored = ign
+		}
+	}
+	return ignored
+}
+
+// matcherFor returns the matcher in effect inside dir and whether dir itself,
+// or one of its parents, is ignored.
+func matcherFor(dir string) (Matcher, bool) {
+	lib := library.ForPath(dir)
+	if lib == nil {
+		return Matcher{}, true
+	}
+
+	m := Matcher{sets: []*ruleSet{parse(lib.Root, []byte(strings.Join(global, "\n")))}}
+	m = m.enter(lib.Root)
+
+	current := lib.Root
+	rel := strings.Trim(strings.TrimPrefix(dir, lib.Root), "/")
+	if rel == "" {
+		return m, false
+	}
+
+	for _, part := range strings.Split(rel, "/") {
+		current = filepath.Join(current, part)
+		if m.ignored(current, true) {
+			return m, true
+		}
+		m = m.enter(current)
+	}
+
+	return m, false
+}
+
+// Ignored reports whether a single path is excluded from its library.
+func Ignored(path string, isDir bool) bool {
+	lib := library.ForPath(path)
+	if lib == nil {
+		return true
+	}
+	if path == lib.Root {
+		return false
+	}
+
+	if !followSymlinks {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+
+	m, ignored := matcherFor(filepath.Dir(path))
+	return ignored || m.ignored(path, isDir)
+}
+
+// Walk calls fn for every file and directory below root that is not ignored.
+// Symlinks are skipped unless following them is enabled, in which case each
+// directory is visited at most once to avoid loops.
+func Walk(root string, fn func(path string, info os.FileInfo) error) error {
+	m, ignored := matcherFor(root)
+	if ignored {
+		return nil
+	}
+
+	// matcherFor has read the rules of root already
+	visited := make(map[string]bool)
+	return walk(root, m, visited, fn)
+}
+
+func walk(dir string, m Matcher, visited map[string]bool, fn func(path string, info os.FileInfo) error) error {
+	if followSymlinks {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if visited[real] {
+			return nil
+		}
+		visited[real] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !followSymlinks {
+				continue
+			}
+			info, err = os.Stat(path)
+			if err != nil {
+				// broken link
+				continue
+			}
+		}
+
+		if m.ignored(path, info.IsDir()) {
+			continue
+		}
+
+		if err := fn(path, info); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if err := walk(path, m.enter(path), visited, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ruleSet holds the patterns of one .shelfignore file. Patterns are matched
+// against paths relative to base, the directory the file lives in.
+type ruleSet struct {
+	base  string
+	rules []rule
+}
+
+// parse reads gitignore-style patterns: '#' comments, '!' negation, a
+// trailing '/' for directories only, a leading or inner '/' to anchor the
+// pattern to base, and '*', '?', '[...]' and '**' wildcards.
+func parse(base string, data []byte) *ruleSet {
+	set := &ruleSet{base: base}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			line = line[1:]
+		} else if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+
+		re, err := regexp.Compile(toRegexp(line))
+		if err != nil {
+			continue
+		}
+		r.re = re
+
+		set.rules = append(set.rules, r)
+	}
+
+	return set
+}
+
+// match reports whether any pattern matches path, and if so whether the last
+// matching pattern ignores it.
+func (s *ruleSet) match(path string, isDir bool) (matched, ignored bool) {
+	rel, err := filepath.Rel(s.base, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, r := range s.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(rel) {
+			matched = true
+			ignored = !r.negate
+		}
+	}
+
+	return matched, ignored
+}
+
+func toRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		case pattern[i] == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				i++
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 2
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i += 2
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
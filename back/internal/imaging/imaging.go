@@ -0,0 +1,123 @@
+// Package imaging decodes, resizes and encodes the images served for covers
+// and book pages.
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+
+	_ "golang.org/x/image/bmp"
+
+	"github.com/chai2010/webp"
+	"golang.org/x/image/draw"
+)
+
+// Formats that pages can be encoded to.
+const (
+	WebP = "webp"
+	JPEG = "jpeg"
+	PNG  = "png"
+)
+
+// MaxPixels bounds the size of the images Decode accepts, about 200 MB once
+// decoded, so that a single huge page cannot exhaust the memory.
+const MaxPixels = 50_000_000
+
+// Decode reads any image format the server handles, including WebP.
+func Decode(imgData []byte) (image.Image, error) {
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(imgData)); err == nil {
+		if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+			return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imgData))
+	if err == nil {
+		return img, nil
+	}
+
+	// WebP fallback
+	img, err = webp.Decode(bytes.NewReader(imgData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image (even with WebP): %w", err)
+	}
+
+	return img, nil
+}
+
+// Fit scales img down to fit within maxWidth x maxHeight, keeping the aspect
+// ratio. A bound of 0 is not applied, and images are never scaled up.
+func Fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale >= 1 {
+		return img
+	}
+
+	newW := max(1, int(float64(width)*scale))
+	newH := max(1, int(float64(height)*scale))
+	dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
+	// pages are read at full size, so they get a sharper filter than covers
+	draw.CatmullRom.Scale(dst, dst.Rect, img, bounds, draw.Over, nil)
+	return dst
+}
+
+// Thumbnail scales img down so that its short side is at most size. Covers
+// are small, so a cheaper filter than Fit's is good enough.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	short := min(width, height)
+	if short <= size {
+		return img
+	}
+
+	scale := float64(size) / float64(short)
+	newW := max(1, int(float64(width)*scale))
+	newH := max(1, int(float64(height)*scale))
+	dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
+	draw.ApproxBiLinear.Scale(dst, dst.Rect, img, bounds, draw.Over, nil)
+	return dst
+}
+
+// Encode writes img in format with the given quality (1-100), which PNG
+// ignores.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case WebP:
+		return webp.Encode(w, img, &webp.Options{Lossless: false, Quality: float32(quality)})
+	case JPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case PNG:
+		return png.Encode(w, img)
+	default:
+		return fmt.Errorf("unsupported image format: %s", format)
+	}
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	switch format {
+	case WebP:
+		return "image/webp"
+	case JPEG:
+		return "image/jpeg"
+	default:
+		return "image/png"
+	}
+}
@@ -0,0 +1,183 @@
+package library
+
+import (
+	"back/internal/config"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type Library struct {
+	Name      string
+	Root      string
+	Direction string
+	Formats   []string
+}
+
+var libraries []Library
+
+var ErrInvalidPath = errors.New("invalid path")
+
+var supportedFormats = map[string]bool{
+	"PDF":  true,
+	"EPUB": true,
+	"CBZ":  true,
+	"CBR":  true,
+}
+
+// Init validates the configured libraries and makes them available to the
+// scanner and the API.
+func Init(cfgs []config.Library) error {
+	var libs []Library
+	names := make(map[string]bool)
+
+	for _, cfg := range cfgs {
+		lib := Library{
+			Name:      strings.TrimSpace(cfg.Name),
+			Root:      filepath.Clean(cfg.Root),
+			Direction: strings.ToLower(cfg.Direction),
+		}
+
+		if lib.Name == "" || strings.Contains(lib.Name, "/") {
+			return fmt.Errorf("invalid library name: %q", cfg.Name)
+		}
+		if names[lib.Name] {
+			return fmt.Errorf("duplicate library name: %s", lib.Name)
+		}
+		names[lib.Name] = true
+
+		if !filepath.IsAbs(lib.Root) || lib.Root == "/" {
+			return fmt.Errorf("library %s: root must be an absolute path below /", lib.Name)
+		}
+
+		switch lib.Direction {
+		case "":
+			lib.Direction = "ltr"
+		case "ltr", "rtl":
+		default:
+			return fmt.Errorf("library %s: direction must be ltr or rtl", lib.Name)
+		}
+
+		for _, f := range cfg.Formats {
+			format := strings.ToUpper(strings.TrimPrefix(f, "."))
+			if !supportedFormats[format] {
+				return fmt.Errorf("library %s: unsupported format %s", lib.Name, f)
+			}
+			lib.Formats = append(lib.Formats, format)
+		}
+		if len(lib.Formats) == 0 {
+			lib.Formats = []string{"PDF", "EPUB", "CBZ", "CBR"}
+		}
+
+		for _, other := range libs {
+			if isWithin(lib.Root, other.Root) || isWithin(other.Root, lib.Root) {
+				return fmt.Errorf("library roots overlap: %s and %s", lib.Root, other.Root)
+			}
+		}
+
+		libs = append(libs, lib)
+	}
+
+	libraries = libs
+	return nil
+}
+
+func All() []Library {
+	return libraries
+}
+
+func Roots() []string {
+	roots := make([]string, len(libraries))
+	for i, lib := range libraries {
+		roots[i] = lib.Root
+	}
+	return roots
+}
+
+func Get(name string) *Library {
+	for i := range libraries {
+		if libraries[i].Name == name {
+			return &libraries[i]
+		}
+	}
+	return nil
+}
+
+// ForPath returns the library containing the given file system path.
+func ForPath(fsPath string) *Library {
+	for i := range libraries {
+		if isWithin(fsPath, libraries[i].Root) {
+			return &libraries[i]
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the library serves the format of the given file.
+func (l *Library) Allows(fsPath string) bool {
+	format := strings.ToUpper(strings.TrimPrefix(filepath.Ext(fsPath), "."))
+	for _, f := range l.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// APIPath returns the path of the library root as seen by the API.
+func (l *Library) APIPath() string {
+	return ToAPI(l.Root)
+}
+
+// ToAPI converts a file system path into the path used by the API. With a
+// single library, API paths are relative to its root; with several, they
+// start with the library name.
+func ToAPI(fsPath string) string {
+	lib := ForPath(fsPath)
+	if lib == nil {
+		return ""
+	}
+
+	rel := strings.TrimPrefix(fsPath, lib.Root)
+	if rel == "" {
+		rel = "/"
+	}
+	if len(libraries) == 1 {
+		return rel
+	}
+	return "/" + lib.Name + strings.TrimSuffix(rel, "/")
+}
+
+// ToFS converts a path used by the API into a file system path. Paths that
+// escape the library root are rejected.
+func ToFS(apiPath string) (string, *Library, error) {
+	clean := filepath.Clean("/" + strings.TrimPrefix(apiPath, "/"))
+
+	var lib *Library
+	rel := clean
+	if len(libraries) == 1 {
+		lib = &libraries[0]
+	} else {
+		parts := strings.SplitN(strings.TrimPrefix(clean, "/"), "/", 2)
+		lib = Get(parts[0])
+		if lib == nil {
+			return "", nil, ErrInvalidPath
+		}
+		rel = "/"
+		if len(parts) == 2 {
+			rel += parts[1]
+		}
+	}
+
+	fsPath := filepath.Join(lib.Root, rel)
+	if !isWithin(fsPath, lib.Root) {
+		return "", nil, ErrInvalidPath
+	}
+
+	return fsPath, lib, nil
+}
+
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
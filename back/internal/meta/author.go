@@ -0,0 +1,86 @@
+package meta
+
+import (
+	"regexp"
+	"strings"
+)
+
+// nameSuffixes are kept at the end of a name instead of being taken for the
+// family name or the given names.
+var nameSuffixes = map[string]bool{
+	"jr": true, "jr.": true, "sr": true, "sr.": true,
+	"ii": true, "iii": true, "iv": true,
+	"phd": true, "ph.d.": true,
+}
+
+// nameSeparator separates the people in a list of names. Commas are handled
+// by splitNames, since they also appear in "Family, Given".
+var nameSeparator = regexp.MustCompile(`(?i)\s*(?:;|&|\band\b)\s*`)
+
+// splitNames splits a field that lists several people, such as
+// "Alice Smith, Bob Jones" or "Alice Smith & Bob Jones". A single comma after
+// a one-word family name is taken for "Family, Given" instead.
+func splitNames(s string) []string {
+	var names []string
+	for _, group := range nameSeparator.Split(s, -1) {
+		var parts []string
+		for _, part := range strings.Split(group, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+
+		if len(parts) == 2 && (len(strings.Fields(parts[0])) == 1 || nameSuffixes[strings.ToLower(parts[1])]) {
+			names = append(names, parts[0]+", "+parts[1])
+			continue
+		}
+		names = append(names, parts...)
+	}
+	return names
+}
+
+// NewContributor returns a contributor with a normalised name and sort name.
+func NewContributor(name, role string) Contributor {
+	return normaliseContributor(Contributor{Name: name, Role: role})
+}
+
+// normaliseContributor turns "Tolkien, J. R. R." into "J. R. R. Tolkien" and
+// fills the sort name when the file does not provide one.
+func normaliseContributor(c Contributor) Contributor {
+	name := strings.Join(strings.Fields(c.Name), " ")
+	sortName := strings.Join(strings.Fields(c.SortName), " ")
+
+	if family, given, ok := strings.Cut(name, ","); ok && !strings.Contains(given, ",") {
+		family = strings.TrimSpace(family)
+		given = strings.TrimSpace(given)
+		if len(strings.Fields(family)) == 1 && given != "" && !nameSuffixes[strings.ToLower(given)] {
+			if sortName == "" {
+				sortName = family + ", " + given
+			}
+			name = given + " " + family
+		}
+	}
+
+	if sortName == "" {
+		sortName = sortNameOf(name)
+	}
+
+	return Contributor{Name: name, SortName: sortName, SortNameGiven: c.SortNameGiven, Role: c.Role}
+}
+
+// sortNameOf moves the last name to the front. Names written without spaces,
+// as usual in Japanese and Chinese, are left as they are.
+func sortNameOf(name string) string {
+	parts := strings.Fields(strings.ReplaceAll(name, ",", ""))
+
+	last := len(parts) - 1
+	if last > 0 && nameSuffixes[strings.ToLower(parts[last])] {
+		last--
+	}
+	if last < 1 {
+		return name
+	}
+
+	rest := append(append([]string{}, parts[:last]...), parts[last+1:]...)
+	return parts[last] + ", " + strings.Join(rest, " ")
+}
@@ -0,0 +1,99 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitNames(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"J. R. R. Tolkien", []string{"J. R. R. Tolkien"}},
+		{"Tolkien, J. R. R.", []string{"Tolkien, J. R. R."}},
+		{"Alice Smith, Bob Jones", []string{"Alice Smith", "Bob Jones"}},
+		{"Alice Smith, Bob Jones, Carol White", []string{"Alice Smith", "Bob Jones", "Carol White"}},
+		{"Smith, Alice, Jones, Bob", []string{"Smith", "Alice", "Jones", "Bob"}},
+		{"Alice Smith & Bob Jones", []string{"Alice Smith", "Bob Jones"}},
+		{"Alice Smith and Bob Jones", []string{"Alice Smith", "Bob Jones"}},
+		{"Alice Smith AND Bob Jones", []string{"Alice Smith", "Bob Jones"}},
+		{"Alice Smith; Bob Jones", []string{"Alice Smith", "Bob Jones"}},
+		{"Smith, Alice; Jones, Bob", []string{"Smith, Alice", "Jones, Bob"}},
+		{"Tolkien, J. R. R. and Lewis, C. S.", []string{"Tolkien, J. R. R.", "Lewis, C. S."}},
+		{"Martin Luther King, Jr.", []string{"Martin Luther King, Jr."}},
+		{"Alexander Anderson", []string{"Alexander Anderson"}},
+		{"Alice Smith, ", []string{"Alice Smith"}},
+		{" ; ", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitNames(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitNames(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormaliseContributor(t *testing.T) {
+	tests := []struct {
+		in   Contributor
+		want Contributor
+	}{
+		{
+			Contributor{Name: "J. R. R. Tolkien"},
+			Contributor{Name: "J. R. R. Tolkien", SortName: "Tolkien, J. R. R."},
+		},
+		{
+			Contributor{Name: "Tolkien, J. R. R."},
+			Contributor{Name: "J. R. R. Tolkien", SortName: "Tolkien, J. R. R."},
+		},
+		{
+			// only a one-word family name is flipped
+			Contributor{Name: "Alice Smith, Bob Jones"},
+			Contributor{Name: "Alice Smith, Bob Jones", SortName: "Jones, Alice Smith Bob"},
+		},
+		{
+			Contributor{Name: "Martin Luther King, Jr."},
+			Contributor{Name: "Martin Luther King, Jr.", SortName: "King, Martin Luther Jr."},
+		},
+		{
+			Contributor{Name: "Ursula K. Le Guin", SortName: "Le Guin, Ursula K.", SortNameGiven: true},
+			Contributor{Name: "Ursula K. Le Guin", SortName: "Le Guin, Ursula K.", SortNameGiven: true},
+		},
+		{
+			Contributor{Name: "  Jane   Austen ", Role: RoleAuthor},
+			Contributor{Name: "Jane Austen", SortName: "Austen, Jane", Role: RoleAuthor},
+		},
+		{
+			Contributor{Name: "尾田栄一郎"},
+			Contributor{Name: "尾田栄一郎", SortName: "尾田栄一郎"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := normaliseContributor(tt.in); got != tt.want {
+			t.Errorf("normaliseContributor(%+v) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormaliseSplitsAuthorLists(t *testing.T) {
+	m := Normalise(Meta{
+		Title: "Book",
+		Contributors: []Contributor{
+			{Name: "Alice Smith, Bob Jones", Role: RoleAuthor},
+			{Name: "Le Guin, Ursula K.", SortName: "Le Guin, Ursula K.", SortNameGiven: true, Role: RoleAuthor},
+		},
+		Series: "Series",
+	}, "/books/Book.pdf")
+
+	want := []Contributor{
+		{Name: "Alice Smith", SortName: "Smith, Alice", Role: RoleAuthor},
+		{Name: "Bob Jones", SortName: "Jones, Bob", Role: RoleAuthor},
+		{Name: "Le Guin, Ursula K.", SortName: "Le Guin, Ursula K.", SortNameGiven: true, Role: RoleAuthor},
+	}
+	if !reflect.DeepEqual(m.Contributors, want) {
+		t.Errorf("contributors = %+v, want %+v", m.Contributors, want)
+	}
+}
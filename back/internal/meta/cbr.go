@@ -1,31 +1,29 @@
 package meta
 
 import (
+	"back/internal/archive"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
 )
 
-// CBRMeta holds extracted metadata
-type CBRMeta struct {
-	Title    string
-	Keywords []string
-	ModTime  time.Time
-}
-
-// TODO
-func extractCBRMeta(path string) (string, []string, int64, error) {
-	info, err := os.Stat(path)
+// extractCBRMeta reads ComicInfo.xml, or the ComicBookInfo comment of the
+// archive, and falls back to the file name for the title. RAR is not
+// supported by the Go standard library, so CBR is still read through 7z.
+func extractCBRMeta(path string) (Meta, error) {
+	entries, comment, err := archive.List7z(path)
 	if err != nil {
-		return "", nil, 0, fmt.Errorf("failed to stat file: %w", err)
+		return Meta{}, fmt.Errorf("7z list command failed: %w", err)
 	}
-	modTime := info.ModTime().Unix()
 
-	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
-
-	var keywords []string
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name)
+	}
 
-	return title, keywords, modTime, nil
+	return extractComicMeta(path, comicArchive{
+		files:   files,
+		comment: comment,
+		read: func(name string) ([]byte, error) {
+			return archive.Extract7z(path, name)
+		},
+	})
 }
@@ -1,31 +1,23 @@
 package meta
 
-import (
-	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
-)
+import "back/internal/archive"
 
-// CBZMeta holds extracted metadata
-type CBZMeta struct {
-	Title    string
-	Keywords []string
-	ModTime  time.Time
-}
-
-// TODO
-func extractCBZMeta(path string) (string, []string, int64, error) {
-	info, err := os.Stat(path)
+// extractCBZMeta reads ComicInfo.xml, or the ComicBookInfo comment of the
+// archive, and falls back to the file name for the title.
+func extractCBZMeta(path string) (Meta, error) {
+	ar, err := archive.Open(path)
 	if err != nil {
-		return "", nil, 0, fmt.Errorf("failed to stat file: %w", err)
+		// many .cbz files are RAR or 7z archives that were only renamed
+		if m, err7z := extractCBRMeta(path); err7z == nil {
+			return m, nil
+		}
+		return Meta{}, err
 	}
-	modTime := info.ModTime().Unix()
-
-	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
-
-	var keywords []string
+	defer ar.Close()
 
-	return title, keywords, modTime, nil
+	return extractComicMeta(path, comicArchive{
+		files:   ar.Names(),
+		comment: ar.Comment(),
+		read:    ar.ReadFile,
+	})
 }
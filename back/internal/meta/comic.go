@@ -0,0 +1,256 @@
+package meta
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// comicInfo is the ComicInfo.xml schema used by ComicRack and most comic
+// taggers. Credits, genres and tags are comma separated.
+type comicInfo struct {
+	Title       string `xml:"Title"`
+	Series      string `xml:"Series"`
+	Number      string `xml:"Number"`
+	Volume      string `xml:"Volume"`
+	Summary     string `xml:"Summary"`
+	Year        int    `xml:"Year"`
+	Month       int    `xml:"Month"`
+	Day         int    `xml:"Day"`
+	Writer      string `xml:"Writer"`
+	Penciller   string `xml:"Penciller"`
+	Inker       string `xml:"Inker"`
+	Colorist    string `xml:"Colorist"`
+	Letterer    string `xml:"Letterer"`
+	CoverArtist string `xml:"CoverArtist"`
+	Editor      string `xml:"Editor"`
+	Translator  string `xml:"Translator"`
+	Publisher   string `xml:"Publisher"`
+	Genre       string `xml:"Genre"`
+	Tags        string `xml:"Tags"`
+	PageCount   int    `xml:"PageCount"`
+	LanguageISO string `xml:"LanguageISO"`
+	GTIN        string `xml:"GTIN"`
+	Manga       string `xml:"Manga"`
+}
+
+// comicBookInfo is the JSON that ComicBookLover stores in the archive comment.
+type comicBookInfo struct {
+	Info struct {
+		Title            string   `json:"title"`
+		Series           string   `json:"series"`
+		Issue            any      `json:"issue"`
+		Volume           any      `json:"volume"`
+		Publisher        string   `json:"publisher"`
+		PublicationYear  int      `json:"publicationYear"`
+		PublicationMonth int      `json:"publicationMonth"`
+		Genre            string   `json:"genre"`
+		Tags             []string `json:"tags"`
+		Language         string   `json:"language"`
+		Comments         string   `json:"comments"`
+		Credits          []struct {
+			Person string `json:"person"`
+			Role   string `json:"role"`
+		} `json:"credits"`
+	} `json:"ComicBookInfo/1.0"`
+}
+
+// comicArchive is what comic metadata is read from, so that CBZ and CBR can
+// share the parsing while reading their archives differently.
+type comicArchive struct {
+	files   []string
+	comment string
+	read    func(name string) ([]byte, error)
+}
+
+func extractComicMeta(path string, ar comicArchive) (Meta, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Meta{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	m, err := readComicMeta(ar)
+	if err != nil {
+		// a broken ComicInfo.xml must not keep the comic out of the library,
+		// it is still listed under its file name
+		log.Printf("%s: %v", path, err)
+		m = Meta{}
+	}
+
+	m.ModTime = info.ModTime().Unix()
+	if m.Title == "" {
+		m.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return m, nil
+}
+
+func readComicMeta(ar comicArchive) (Meta, error) {
+	for _, name := range ar.files {
+		if !strings.EqualFold(filepath.Base(name), "ComicInfo.xml") {
+			continue
+		}
+		data, err := ar.read(name)
+		if err != nil {
+			return Meta{}, fmt.Errorf("failed to extract ComicInfo.xml: %w", err)
+		}
+		var ci comicInfo
+		if err := xml.Unmarshal(data, &ci); err != nil {
+			return Meta{}, fmt.Errorf("failed to parse ComicInfo.xml: %w", err)
+		}
+		return ci.meta(), nil
+	}
+
+	// the comment may hold anything, so only well-formed ComicBookInfo is used
+	comment := ar.comment
+	if start, end := strings.Index(comment, "{"), strings.LastIndex(comment, "}"); start != -1 && end > start {
+		var cbi comicBookInfo
+		if err := json.Unmarshal([]byte(comment[start:end+1]), &cbi); err == nil {
+			return cbi.meta(), nil
+		}
+	}
+
+	return Meta{}, nil
+}
+
+func (ci comicInfo) meta() Meta {
+	number := ci.Number
+	if number == "" {
+		number = ci.Volume
+	}
+
+	m := Meta{
+		Title:        ci.Title,
+		Series:       strings.TrimSpace(ci.Series),
+		SeriesNumber: strings.TrimSpace(number),
+		Publisher:    strings.TrimSpace(ci.Publisher),
+		Language:     strings.TrimSpace(ci.LanguageISO),
+		Description:  strings.TrimSpace(ci.Summary),
+		Published:    comicDate(ci.Year, ci.Month, ci.Day),
+		Pages:        ci.PageCount,
+	}
+
+	credits := []struct{ names, role string }{
+		{ci.Writer, RoleWriter},
+		{ci.Penciller, "penciller"},
+		{ci.Inker, "inker"},
+		{ci.Colorist, "colorist"},
+		{ci.Letterer, "letterer"},
+		{ci.CoverArtist, "cover artist"},
+		{ci.Editor, "editor"},
+		{ci.Translator, "translator"},
+	}
+	for _, credit := range credits {
+		for _, name := range splitList(credit.names) {
+			m.Contributors = append(m.Contributors, Contributor{Name: name, Role: credit.role})
+		}
+	}
+
+	for _, gtin := range splitList(ci.GTIN) {
+		if id, ok := parseIdentifier("", gtin); ok {
+			m.Identifiers = append(m.Identifiers, id)
+		}
+	}
+
+	switch ci.Manga {
+	case "Yes", "YesAndRightToLeft":
+		m.Direction = "rtl"
+	case "No":
+		m.Direction = "ltr"
+	}
+
+	m.Keywords = comicKeywords(m, splitList(ci.Genre), splitList(ci.Tags))
+	return m
+}
+
+func (cbi comicBookInfo) meta() Meta {
+	info := cbi.Info
+
+	number := jsonNumber(info.Issue)
+	if number == "" {
+		number = jsonNumber(info.Volume)
+	}
+
+	m := Meta{
+		Title:        info.Title,
+		Series:       strings.TrimSpace(info.Series),
+		SeriesNumber: number,
+		Publisher:    strings.TrimSpace(info.Publisher),
+		Language:     strings.TrimSpace(info.Language),
+		Description:  strings.TrimSpace(info.Comments),
+		Published:    comicDate(info.PublicationYear, info.PublicationMonth, 0),
+	}
+
+	for _, c := range info.Credits {
+		person := strings.TrimSpace(c.Person)
+		if person == "" {
+			continue
+		}
+		role := strings.ToLower(strings.TrimSpace(c.Role))
+		if role == "" {
+			role = RoleContributor
+		}
+		m.Contributors = append(m.Contributors, Contributor{Name: person, Role: role})
+	}
+
+	m.Keywords = comicKeywords(m, splitList(info.Genre), info.Tags)
+	return m
+}
+
+func comicKeywords(m Meta, lists ...[]string) []string {
+	var keywords []string
+	for _, c := range m.Contributors {
+		keywords = append(keywords, c.Name)
+	}
+	for _, list := range lists {
+		keywords = append(keywords, list...)
+	}
+	if m.Series != "" {
+		keywords = append(keywords, m.Series)
+	}
+	if m.Publisher != "" {
+		keywords = append(keywords, m.Publisher)
+	}
+	return keywords
+}
+
+// comicDate formats the parts of the date that are set.
+func comicDate(year, month, day int) string {
+	switch {
+	case year <= 0:
+		return ""
+	case month <= 0:
+		return fmt.Sprintf("%04d", year)
+	case day <= 0:
+		return fmt.Sprintf("%04d-%02d", year, month)
+	default:
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// jsonNumber formats an issue or volume, which taggers write either as a
+// number or as a string.
+func jsonNumber(v any) string {
+	switch n := v.(type) {
+	case string:
+		return strings.TrimSpace(n)
+	case float64:
+		return fmt.Sprint(n)
+	default:
+		return ""
+	}
+}
@@ -1,34 +1,32 @@
 package meta
 
 import (
-	"bytes"
+	"back/internal/archive"
 	"encoding/xml"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
-type EPUBMeta struct {
-	Title    string
-	Keywords []string
-	ModTime  time.Time
-}
-
 // ExtractEPUBMeta extracts metadata and last modified time from the given EPUB file
-func extractEPUBMeta(path string) (string, []string, int64, error) {
+func extractEPUBMeta(path string) (Meta, error) {
 	info, err := os.Stat(path)
 	if err != nil {
-		return "", nil, 0, fmt.Errorf("failed to stat file: %w", err)
+		return Meta{}, fmt.Errorf("failed to stat file: %w", err)
 	}
 	modTime := info.ModTime().Unix()
 
+	ar, err := archive.Open(path)
+	if err != nil {
+		return Meta{}, err
+	}
+	defer ar.Close()
+
 	// Step 1: Get path to content.opf
-	containerXML, err := run7zCommand(path, "META-INF/container.xml")
+	containerXML, err := ar.ReadFile("META-INF/container.xml")
 	if err != nil {
-		return "", nil, 0, fmt.Errorf("failed to extract container.xml: %w", err)
+		return Meta{}, fmt.Errorf("failed to extract container.xml: %w", err)
 	}
 
 	var container struct {
@@ -40,59 +38,30 @@ func extractEPUBMeta(path string) (string, []string, int64, error) {
 	}
 
 	if err := xml.Unmarshal(containerXML, &container); err != nil {
-		return "", nil, 0, fmt.Errorf("failed to parse container.xml: %w", err)
+		return Meta{}, fmt.Errorf("failed to parse container.xml: %w", err)
 	}
 
 	contentPath := container.Rootfiles.Rootfile.FullPath
 	if contentPath == "" {
-		return "", nil, 0, fmt.Errorf("content.opf path not found in container.xml")
+		return Meta{}, fmt.Errorf("content.opf path not found in container.xml")
 	}
 
 	// Step 2: Extract content.opf
-	opfData, err := run7zCommand(path, contentPath)
+	opfData, err := ar.ReadFile(contentPath)
 	if err != nil {
-		return "", nil, 0, fmt.Errorf("failed to extract content.opf: %w", err)
+		return Meta{}, fmt.Errorf("failed to extract content.opf: %w", err)
 	}
 
 	// Step 3: Parse metadata from content.opf
-	type metadata struct {
-		Title   string   `xml:"title"`
-		Creator string   `xml:"creator"`
-		Subject []string `xml:"subject"`
-	}
-
-	var pkg struct {
-		Metadata metadata `xml:"metadata"`
-	}
-
-	if err := xml.Unmarshal(opfData, &pkg); err != nil {
-		return "", nil, 0, fmt.Errorf("failed to parse content.opf: %w", err)
-	}
-
-	title := pkg.Metadata.Title
-	if title == "" {
-		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	m, err := ParseOPF(opfData)
+	if err != nil {
+		return Meta{}, fmt.Errorf("failed to parse content.opf: %w", err)
 	}
 
-	var keywords []string
-	if pkg.Metadata.Creator != "" {
-		keywords = append(keywords, pkg.Metadata.Creator)
+	if m.Title == "" {
+		m.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
 	}
-	if len(pkg.Metadata.Subject) > 0 {
-		keywords = append(keywords, pkg.Metadata.Subject...)
-	}
-
-	return title, keywords, modTime, nil
-}
+	m.ModTime = modTime
 
-// run7zCommand extracts a single file from the EPUB archive using 7z and returns its contents.
-func run7zCommand(epubPath, internalPath string) ([]byte, error) {
-	cmd := exec.Command("7z", "x", "-so", epubPath, internalPath)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return nil, err
-	}
-	return out.Bytes(), nil
+	return m, nil
 }
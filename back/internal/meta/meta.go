@@ -1,18 +1,123 @@
 package meta
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Version is stored with every book and increased whenever the extractors
+// learn to read new fields, so that books scanned by older versions are
+// extracted again.
+const Version = 5
+
+const (
+	RoleAuthor      = "author"
+	RoleWriter      = "writer"
+	RoleContributor = "contributor"
+)
+
+type Contributor struct {
+	Name string
+	// SortName is the name as it is sorted, e.g. "Tolkien, J. R. R.".
+	SortName string
+	// SortNameGiven is set when the sort name comes from the file or from
+	// Calibre instead of being guessed from the name.
+	SortNameGiven bool
+	Role          string
+}
+
+// Identifier is an ISBN, UUID or other identifier of the book. Scheme is
+// lower case and empty when the file does not say what the value is.
+type Identifier struct {
+	Scheme string
+	Value  string
+}
+
+// Meta is the metadata read from a book file. Fields the format does not
+// provide are left empty.
+type Meta struct {
+	Title        string
+	Contributors []Contributor
+	// Keywords are indexed for search and include authors and subjects.
+	Keywords []string
+	ModTime  int64
+
+	Series       string
+	SeriesNumber string
+	Publisher    string
+	Language     string
+	// Direction is "rtl" or "ltr" when the file declares how it is read.
+	Direction   string
+	Identifiers []Identifier
+	Description string
+	// Published is the publication date as given by the file, e.g. "2004",
+	// "2004-05" or "2004-05-01".
+	Published string
+	Pages     int
+	// Rating is the number of stars from 0 to 5, 0 when unrated.
+	Rating float64
+}
+
+// Author returns the names of the authors, or of the writers of a comic,
+// separated by commas.
+func (m Meta) Author() string {
+	var names []string
+	for _, c := range m.Contributors {
+		if c.Role == RoleAuthor || c.Role == RoleWriter {
+			names = append(names, c.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func ExtractMeta(path, bookType string) (Meta, error) {
+	var m Meta
+	var err error
 
-func ExtractMeta(path, bookType string) (string, []string, int64, error) {
 	switch bookType {
 	case "PDF":
-		return extractPDFMeta(path)
+		m, err = extractPDFMeta(path)
 	case "EPUB":
-		return extractEPUBMeta(path)
+		m, err = extractEPUBMeta(path)
 	case "CBZ":
-		return extractCBZMeta(path)
+		m, err = extractCBZMeta(path)
 	case "CBR":
-		return extractCBRMeta(path)
+		m, err = extractCBRMeta(path)
 	default:
-		return "", nil, 0, fmt.Errorf("unsupported file type: %s", bookType)
+		return Meta{}, fmt.Errorf("unsupported file type: %s", bookType)
+	}
+	if err != nil {
+		return Meta{}, err
+	}
+
+	return Normalise(m, path), nil
+}
+
+// Normalise fills in what the metadata of the book at path does not say
+// itself: single contributors from lists of names, their sort names, and a
+// series guessed from the file name.
+func Normalise(m Meta, path string) Meta {
+	var contributors []Contributor
+	for _, c := range m.Contributors {
+		names := []string{c.Name}
+		if !c.SortNameGiven {
+			names = splitNames(c.Name)
+		}
+		for _, name := range names {
+			c.Name = name
+			contributors = append(contributors, normaliseContributor(c))
+		}
 	}
+	m.Contributors = contributors
+
+	if m.Series == "" {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		m.Series, m.SeriesNumber = seriesFromFileName(name)
+		if m.Series != "" {
+			m.Keywords = append(m.Keywords, m.Series)
+		}
+	}
+
+	return m
 }
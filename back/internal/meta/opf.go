@@ -0,0 +1,223 @@
+package meta
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type opfContributor struct {
+	ID     string `xml:"id,attr"`
+	Role   string `xml:"role,attr"`
+	FileAs string `xml:"file-as,attr"`
+	Name   string `xml:",chardata"`
+}
+
+type opfIdentifier struct {
+	ID     string `xml:"id,attr"`
+	Scheme string `xml:"scheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type opfDate struct {
+	Event string `xml:"event,attr"`
+	Value string `xml:",chardata"`
+}
+
+// opfMeta covers both the EPUB 2 form (name and content) and the EPUB 3 form
+// (property, refines and the element text).
+type opfMeta struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type opfMetadata struct {
+	Titles       []string         `xml:"title"`
+	Creators     []opfContributor `xml:"creator"`
+	Contributors []opfContributor `xml:"contributor"`
+	Subjects     []string         `xml:"subject"`
+	Publisher    string           `xml:"publisher"`
+	Languages    []string         `xml:"language"`
+	Identifiers  []opfIdentifier  `xml:"identifier"`
+	Description  string           `xml:"description"`
+	Dates        []opfDate        `xml:"date"`
+	Metas        []opfMeta        `xml:"meta"`
+}
+
+// marcRoles maps the MARC relator codes used by OPF to role names.
+var marcRoles = map[string]string{
+	"aut": RoleAuthor,
+	"ctb": RoleContributor,
+	"edt": "editor",
+	"ill": "illustrator",
+	"trl": "translator",
+	"nrt": "narrator",
+	"art": "artist",
+}
+
+var isbnPattern = regexp.MustCompile(`^(97[89])?\d{9}[\dXx]$`)
+
+// ParseOPF reads the metadata of an OPF package document, as found in EPUB
+// files and next to books in Calibre libraries. The title is left empty when
+// the document has none.
+func ParseOPF(data []byte) (Meta, error) {
+	var pkg struct {
+		Metadata opfMetadata `xml:"metadata"`
+	}
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return Meta{}, err
+	}
+	md := pkg.Metadata
+
+	// EPUB 3 attaches roles, identifier types and series positions to
+	// elements through <meta refines="#id">
+	refines := make(map[string][]opfMeta)
+	for _, meta := range md.Metas {
+		if id, ok := strings.CutPrefix(meta.Refines, "#"); ok {
+			refines[id] = append(refines[id], meta)
+		}
+	}
+	refined := func(id, property string) string {
+		if id == "" {
+			return ""
+		}
+		for _, meta := range refines[id] {
+			if meta.Property == property {
+				return strings.TrimSpace(meta.Value)
+			}
+		}
+		return ""
+	}
+
+	var m Meta
+
+	for _, title := range md.Titles {
+		if title = strings.TrimSpace(title); title != "" {
+			m.Title = title
+			break
+		}
+	}
+
+	addContributors := func(list []opfContributor, defaultRole string) {
+		for _, c := range list {
+			name := strings.TrimSpace(c.Name)
+			if name == "" {
+				continue
+			}
+			code := c.Role
+			if code == "" {
+				code = refined(c.ID, "role")
+			}
+			role, ok := marcRoles[strings.ToLower(code)]
+			if !ok {
+				role = strings.ToLower(code)
+			}
+			if role == "" {
+				role = defaultRole
+			}
+			fileAs := c.FileAs
+			if fileAs == "" {
+				fileAs = refined(c.ID, "file-as")
+			}
+			fileAs = strings.TrimSpace(fileAs)
+			m.Contributors = append(m.Contributors, Contributor{
+				Name:          name,
+				SortName:      fileAs,
+				SortNameGiven: fileAs != "",
+				Role:          role,
+			})
+			m.Keywords = append(m.Keywords, name)
+		}
+	}
+	addContributors(md.Creators, RoleAuthor)
+	addContributors(md.Contributors, RoleContributor)
+
+	for _, subject := range md.Subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			m.Keywords = append(m.Keywords, subject)
+		}
+	}
+
+	m.Publisher = strings.TrimSpace(md.Publisher)
+	if len(md.Languages) > 0 {
+		m.Language = strings.TrimSpace(md.Languages[0])
+	}
+	m.Description = strings.TrimSpace(md.Description)
+
+	for _, id := range md.Identifiers {
+		scheme := id.Scheme
+		if scheme == "" {
+			scheme = refined(id.ID, "identifier-type")
+		}
+		if identifier, ok := parseIdentifier(scheme, id.Value); ok {
+			m.Identifiers = append(m.Identifiers, identifier)
+		}
+	}
+
+	// EPUB 2 may list several dates, only the publication date is wanted
+	for _, date := range md.Dates {
+		if date.Event == "" || strings.EqualFold(date.Event, "publication") {
+			m.Published = strings.TrimSpace(date.Value)
+			break
+		}
+	}
+
+	for _, meta := range md.Metas {
+		switch {
+		case meta.Name == "calibre:series" && m.Series == "":
+			m.Series = strings.TrimSpace(meta.Content)
+		case meta.Name == "calibre:series_index" && m.SeriesNumber == "":
+			m.SeriesNumber = strings.TrimSpace(meta.Content)
+		case meta.Name == "calibre:rating":
+			// Calibre rates from 0 to 10, two points per star
+			if rating, err := strconv.ParseFloat(strings.TrimSpace(meta.Content), 64); err == nil {
+				m.Rating = rating / 2
+			}
+		case meta.Property == "belongs-to-collection" && meta.Refines == "":
+			// a collection without a type may also be a set or a reading list
+			if t := refined(meta.ID, "collection-type"); t != "" && t != "series" {
+				continue
+			}
+			m.Series = strings.TrimSpace(meta.Value)
+			m.SeriesNumber = refined(meta.ID, "group-position")
+		}
+	}
+	if m.Series != "" {
+		m.Keywords = append(m.Keywords, m.Series)
+	}
+
+	return m, nil
+}
+
+// parseIdentifier normalises the scheme of an identifier and recognises the
+// common URN forms and bare ISBNs.
+func parseIdentifier(scheme, value string) (Identifier, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Identifier{}, false
+	}
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+
+	lower := strings.ToLower(value)
+	for _, prefix := range []string{"urn:isbn:", "urn:uuid:", "isbn:", "uuid:"} {
+		if strings.HasPrefix(lower, prefix) {
+			scheme = strings.TrimSuffix(strings.TrimPrefix(prefix, "urn:"), ":")
+			value = value[len(prefix):]
+			break
+		}
+	}
+
+	if scheme == "" || scheme == "isbn" {
+		digits := strings.NewReplacer("-", "", " ", "").Replace(value)
+		if isbnPattern.MatchString(digits) {
+			return Identifier{Scheme: "isbn", Value: digits}, true
+		}
+	}
+
+	return Identifier{Scheme: scheme, Value: value}, true
+}
@@ -1,38 +1,29 @@
 package meta
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 )
 
-// PDFMeta holds extracted metadata
-type PDFMeta struct {
-	Title    string
-	Keywords []string
-	ModTime  time.Time
-}
-
 // ExtractPDFMeta extracts metadata and last modified time from the given PDF file
-func extractPDFMeta(path string) (string, []string, int64, error) {
+func extractPDFMeta(path string) (Meta, error) {
 	info, err := os.Stat(path)
 	if err != nil {
-		return "", nil, 0, fmt.Errorf("failed to stat file: %w", err)
+		return Meta{}, fmt.Errorf("failed to stat file: %w", err)
 	}
 	modTime := info.ModTime().Unix()
 
 	cmd := exec.Command("pdfinfo", path)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
-		return "", nil, 0, fmt.Errorf("failed to run pdfinfo: %w", err)
+	out, err := cmd.Output()
+	if err != nil {
+		return Meta{}, fmt.Errorf("failed to run pdfinfo: %w", err)
 	}
 
-	lines := strings.Split(out.String(), "\n")
+	lines := strings.Split(string(out), "\n")
 	meta := make(map[string]string)
 	for _, line := range lines {
 		if sep := strings.Index(line, ":"); sep != -1 {
@@ -47,13 +38,24 @@ func extractPDFMeta(path string) (string, []string, int64, error) {
 		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
 	}
 
+	var contributors []Contributor
 	var keywords []string
-	if author := meta["Author"]; author != "" {
+	for _, author := range splitNames(meta["Author"]) {
+		contributors = append(contributors, Contributor{Name: author, Role: RoleAuthor})
 		keywords = append(keywords, author)
 	}
 	if kw := meta["Keywords"]; kw != "" {
 		keywords = append(keywords, strings.Split(kw, ",")...)
 	}
 
-	return title, keywords, modTime, nil
+	pages, _ := strconv.Atoi(meta["Pages"])
+
+	return Meta{
+		Title:        title,
+		Contributors: contributors,
+		Keywords:     keywords,
+		ModTime:      modTime,
+		Description:  meta["Subject"],
+		Pages:        pages,
+	}, nil
 }
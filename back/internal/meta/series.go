@@ -0,0 +1,64 @@
+package meta
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// seriesPatterns recognise volume numbers in file names. A bare trailing
+// number is not enough, since titles such as "Catch 22" would match. Where
+// there is no volume keyword, the number must be a whole 1-3 digits, so that
+// years ("Orwell - 1984") and versions ("Guide v1.2") are left alone.
+var seriesPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^(.+?)[\s_,-]+(?:vol\.?|volume|book|tome|band)\s*(\d+(?:\.\d+)?)\b`),
+	regexp.MustCompile(`(?i)^(.+?)[\s_,-]+v(\d{1,3})(?:$|[\s_,)\]-])`),
+	regexp.MustCompile(`^(.+?)\s*第\s*(\d+(?:\.\d+)?)\s*[巻話]`),
+	regexp.MustCompile(`^(.+?)\s*(\d+(?:\.\d+)?)\s*巻`),
+	regexp.MustCompile(`^(.+?)\s+#(\d+(?:\.\d+)?)\b`),
+	regexp.MustCompile(`^(.+?)\s+-\s+(\d{1,3})(?:$|[\s_,(\[-])`),
+}
+
+// leadingTags matches release tags such as "[Group] " in front of the name.
+var leadingTags = regexp.MustCompile(`^(?:\s*[\[(][^\])]*[\])])+\s*`)
+
+var indexPattern = regexp.MustCompile(`^\d+(?:\.\d+)?`)
+
+// seriesFromFileName guesses the series and volume of a book from its file
+// name, e.g. "[Group] Some Manga v03 (Digital)" is volume 3 of "Some Manga".
+func seriesFromFileName(name string) (string, string) {
+	name = leadingTags.ReplaceAllString(name, "")
+	name = strings.ReplaceAll(name, "_", " ")
+
+	for _, p := range seriesPatterns {
+		match := p.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		series := strings.Trim(match[1], " -_,.")
+		if series == "" {
+			continue
+		}
+		number := strings.TrimLeft(match[2], "0")
+		if number == "" || number[0] == '.' {
+			number = "0" + number
+		}
+		return series, number
+	}
+
+	return "", ""
+}
+
+// SeriesIndex returns the numeric position of a series number such as "3",
+// "3.5" or "12 (Special)", so that volume 10 sorts after volume 2.
+func SeriesIndex(number string) (float64, bool) {
+	match := indexPattern.FindString(strings.TrimSpace(number))
+	if match == "" {
+		return 0, false
+	}
+	index, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
@@ -0,0 +1,71 @@
+package meta
+
+import "testing"
+
+func TestSeriesFromFileName(t *testing.T) {
+	tests := []struct {
+		name   string
+		series string
+		number string
+	}{
+		{"Some Manga v03", "Some Manga", "3"},
+		{"[Group] Some Manga v03 (Digital)", "Some Manga", "3"},
+		{"[Group] (Tag) Some Manga v010 [HQ]", "Some Manga", "10"},
+		{"Some_Manga_v03", "Some Manga", "3"},
+		{"Some Manga, v3", "Some Manga", "3"},
+		{"Some Manga Vol. 2", "Some Manga", "2"},
+		{"Some Manga vol.12", "Some Manga", "12"},
+		{"Some Manga - Volume 7", "Some Manga", "7"},
+		{"Some Manga Vol 7.5", "Some Manga", "7.5"},
+		{"Dune Book 2", "Dune", "2"},
+		{"Asterix Tome 4", "Asterix", "4"},
+		{"Die Drei Band 12", "Die Drei", "12"},
+		{"Batman #012", "Batman", "12"},
+		{"Batman #0", "Batman", "0"},
+		{"Some Manga - 05", "Some Manga", "5"},
+		{"Some Manga - 05 (Digital)", "Some Manga", "5"},
+		{"ワンピース 第3巻", "ワンピース", "3"},
+		{"ワンピース 第12話", "ワンピース", "12"},
+		{"ワンピース 3巻", "ワンピース", "3"},
+
+		// no series
+		{"Some Book", "", ""},
+		{"Catch 22", "", ""},
+		{"Catch-22", "", ""},
+		{"Orwell - 1984", "", ""},
+		{"History of Europe - 2004 Edition", "", ""},
+		{"Guide v1.2", "", ""},
+		{"Report v2024", "", ""},
+		{"Cooking Vegetables", "", ""},
+		{"Guide - v2.0.1", "", ""},
+		{"v03", "", ""},
+	}
+
+	for _, tt := range tests {
+		series, number := seriesFromFileName(tt.name)
+		if series != tt.series || number != tt.number {
+			t.Errorf("seriesFromFileName(%q) = %q, %q, want %q, %q", tt.name, series, number, tt.series, tt.number)
+		}
+	}
+}
+
+func TestSeriesIndex(t *testing.T) {
+	tests := []struct {
+		number string
+		index  float64
+		ok     bool
+	}{
+		{"3", 3, true},
+		{"3.5", 3.5, true},
+		{" 12 (Special)", 12, true},
+		{"Special", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		index, ok := SeriesIndex(tt.number)
+		if index != tt.index || ok != tt.ok {
+			t.Errorf("SeriesIndex(%q) = %v, %v, want %v, %v", tt.number, index, ok, tt.index, tt.ok)
+		}
+	}
+}
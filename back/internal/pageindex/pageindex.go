@@ -0,0 +1,278 @@
+// Package pageindex keeps the ordered page list of comic archives, so that
+// reading a page does not list and sort the whole archive again.
+package pageindex
+
+import (
+	"back/database"
+	"back/internal/archive"
+	"container/list"
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+var capacity int
+
+func init() {
+	capacity = getEnvInt("PAGE_INDEX_CACHE", 64)
+}
+
+func getEnvInt(key string, def int) int {
+	if val := os.Getenv(key); val != "" {
+		if v, err := strconv.Atoi(val); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+type entry struct {
+	path    string
+	modTime int64
+	pages   []database.Page
+}
+
+// call is an index being built, shared by the requests that wait for it.
+type call struct {
+	done  chan struct{}
+	pages []database.Page
+	err   error
+}
+
+var (
+	mu       sync.Mutex
+	order    = list.New() // most recently used first
+	entries  = make(map[string]*list.Element)
+	building = make(map[string]*call)
+	// archives whose page dimensions are being read by fill7z
+	filling = make(map[string]bool)
+)
+
+// Get returns the pages of a CBZ or CBR archive. The index is looked up in
+// memory, then in the database, and only built from the archive when neither
+// matches the current modification time of the file.
+func Get(db *sql.DB, path, bookType string) ([]database.Page, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	modTime := info.ModTime().Unix()
+
+	mu.Lock()
+	if el, ok := entries[path]; ok {
+		e := el.Value.(*entry)
+		if e.modTime == modTime {
+			order.MoveToFront(el)
+			mu.Unlock()
+			return e.pages, nil
+		}
+	}
+	// a reader asks for several pages at once when a book is opened, and
+	// they all wait for the same build
+	if c, ok := building[path]; ok {
+		mu.Unlock()
+		<-c.done
+		return c.pages, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	building[path] = c
+	mu.Unlock()
+
+	c.pages, c.err = load(db, path, bookType, modTime)
+
+	mu.Lock()
+	delete(building, path)
+	if c.err == nil {
+		put(path, modTime, c.pages)
+	}
+	mu.Unlock()
+	close(c.done)
+
+	return c.pages, c.err
+}
+
+// Invalidate drops the cached index of path. The scanner calls it when the
+// file changed.
+func Invalidate(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if el, ok := entries[path]; ok {
+		order.Remove(el)
+		delete(entries, path)
+	}
+}
+
+// put adds an index to the cache. mu must be held.
+func put(path string, modTime int64, pages []database.Page) {
+	if capacity <= 0 {
+		return
+	}
+
+	if el, ok := entries[path]; ok {
+		el.Value = &entry{path, modTime, pages}
+		order.MoveToFront(el)
+		return
+	}
+
+	entries[path] = order.PushFront(&entry{path, modTime, pages})
+	for order.Len() > capacity {
+		oldest := order.Back()
+		order.Remove(oldest)
+		delete(entries, oldest.Value.(*entry).path)
+	}
+}
+
+func load(db *sql.DB, path, bookType string, modTime int64) ([]database.Page, error) {
+	pages, stored, ok, err := database.GetPageIndex(db, path)
+	if err != nil {
+		log.Printf("failed to read page index of %s: %v", path, err)
+	} else if ok && stored == modTime {
+		if missingDimensions(pages) {
+			go fill7z(db, path, modTime, pages)
+		}
+		return pages, nil
+	}
+
+	switch bookType {
+	case "CBZ":
+		pages, err = buildZip(path)
+	case "CBR":
+		pages, err = build7z(path)
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", bookType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no image files found in %s archive", bookType)
+	}
+
+	if err := database.SetPageIndex(db, path, modTime, pages); err != nil {
+		log.Printf("failed to store page index of %s: %v", path, err)
+	}
+	if missingDimensions(pages) {
+		go fill7z(db, path, modTime, pages)
+	}
+	return pages, nil
+}
+
+func buildZip(path string) ([]database.Page, error) {
+	ar, err := archive.Open(path)
+	if err != nil {
+		// many .cbz files are RAR or 7z archives that were only renamed
+		if pages, err7z := build7z(path); err7z == nil {
+			return pages, nil
+		}
+		return nil, err
+	}
+	defer ar.Close()
+
+	var pages []database.Page
+	for _, name := range ar.Images() {
+		page := database.Page{Name: name, Size: ar.Size(name)}
+		if rc, err := ar.Open(name); err == nil {
+			page.Width, page.Height = dimensions(rc)
+			rc.Close()
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// build7z only lists the archive, since reading the dimensions means starting
+// 7z for every page. They are read by fill7z in the background.
+func build7z(path string) ([]database.Page, error) {
+	entries, _, err := archive.List7z(path)
+	if err != nil {
+		return nil, fmt.Errorf("7z list command failed: %w", err)
+	}
+
+	var pages []database.Page
+	for _, e := range entries {
+		if archive.IsImage(e.Name) {
+			pages = append(pages, database.Page{Name: e.Name, Size: e.Size})
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Name < pages[j].Name })
+	return pages, nil
+}
+
+// fill7z reads the dimensions of the pages of a 7z read archive one page at
+// a time and stores them once all are read. Only the header of each page is
+// extracted.
+func fill7z(db *sql.DB, path string, modTime int64, pages []database.Page) {
+	mu.Lock()
+	if filling[path] {
+		mu.Unlock()
+		return
+	}
+	filling[path] = true
+	mu.Unlock()
+
+	defer func() {
+		mu.Lock()
+		delete(filling, path)
+		mu.Unlock()
+	}()
+
+	filled := make([]database.Page, len(pages))
+	copy(filled, pages)
+	for i := range filled {
+		rc, err := archive.Open7z(path, filled[i].Name)
+		if err != nil {
+			log.Printf("7z extract command failed for %s: %v", path, err)
+			return
+		}
+		filled[i].Width, filled[i].Height = dimensions(rc)
+		rc.Close()
+	}
+
+	// the file may have changed while its pages were read
+	if info, err := os.Stat(path); err != nil || info.ModTime().Unix() != modTime {
+		return
+	}
+
+	mu.Lock()
+	if el, ok := entries[path]; ok && el.Value.(*entry).modTime == modTime {
+		el.Value = &entry{path, modTime, filled}
+	}
+	mu.Unlock()
+
+	if err := database.SetPageIndex(db, path, modTime, filled); err != nil {
+		log.Printf("failed to store page index of %s: %v", path, err)
+	}
+}
+
+// missingDimensions is true when no page has known dimensions, as left by
+// build7z before fill7z finished.
+func missingDimensions(pages []database.Page) bool {
+	for _, p := range pages {
+		if p.Width != 0 || p.Height != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dimensions reads only the image header. Unknown formats give 0x0.
+func dimensions(r io.Reader) (int, int) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
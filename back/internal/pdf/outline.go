@@ -0,0 +1,367 @@
+package pdf
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxEntries caps the outline of broken files whose entries form a loop
+// that the visited check does not catch, or that are simply huge.
+const maxEntries = 10000
+
+// Page labels are read from the file, so their numbers and lengths are
+// bounded to keep a broken or hostile file from taking all the memory.
+const (
+	maxLabelStart  = 1000000
+	maxLabelPrefix = 32
+	maxLetters     = 8
+)
+
+// Entry is an item of the outline. Page is 1-based, or 0 when the item does
+// not point to a page of the document.
+type Entry struct {
+	Title    string  `json:"title"`
+	Page     int     `json:"page"`
+	Label    string  `json:"label,omitempty"`
+	Children []Entry `json:"children,omitempty"`
+}
+
+// TOC is the table of contents of a document. PageLabels holds the label of
+// every page, such as "iv" for front matter, and is empty when the document
+// numbers its pages plainly.
+type TOC struct {
+	Pages      int      `json:"pages"`
+	Outline    []Entry  `json:"outline"`
+	PageLabels []string `json:"pageLabels,omitempty"`
+}
+
+// ReadTOC reads the outline (bookmarks) and page labels of the PDF at path.
+func ReadTOC(path string) (TOC, error) {
+	r, err := open(path)
+	if err != nil {
+		return TOC{}, err
+	}
+	defer r.Close()
+
+	catalog, _ := r.resolve(r.trailer["Root"]).(dict)
+	t := &tocReader{reader: r, catalog: catalog, pages: make(map[int]int)}
+	t.walkPages(catalog["Pages"], 0, make(map[int]bool))
+
+	toc := TOC{Pages: t.count, Outline: []Entry{}}
+	toc.PageLabels = t.pageLabels()
+
+	if outlines, ok := r.resolve(catalog["Outlines"]).(dict); ok {
+		if entries := t.entries(outlines["First"], toc.PageLabels, make(map[int]bool), 0); entries != nil {
+			toc.Outline = entries
+		}
+	}
+	return toc, nil
+}
+
+type tocReader struct {
+	*reader
+	catalog dict
+	// page numbers by the object number of the page
+	pages map[int]int
+	count int
+	// the flattened /Names /Dests tree, loaded on first use
+	names map[string]any
+	items int
+}
+
+func (t *tocReader) walkPages(obj any, depth int, seen map[int]bool) {
+	if depth > maxDepth {
+		return
+	}
+	rf, isRef := obj.(ref)
+	if isRef {
+		if seen[rf.num] {
+			return
+		}
+		seen[rf.num] = true
+	}
+
+	node, ok := t.resolve(obj).(dict)
+	if !ok {
+		return
+	}
+	if kids, ok := t.resolve(node["Kids"]).(array); ok && node["Type"] != name("Page") {
+		for _, kid := range kids {
+			t.walkPages(kid, depth+1, seen)
+		}
+		return
+	}
+
+	t.count++
+	if isRef {
+		t.pages[rf.num] = t.count
+	}
+}
+
+func (t *tocReader) entries(obj any, labels []string, seen map[int]bool, depth int) []Entry {
+	var entries []Entry
+	for obj != nil && depth <= maxDepth && t.items < maxEntries {
+		rf, ok := obj.(ref)
+		if !ok || seen[rf.num] {
+			break
+		}
+		seen[rf.num] = true
+
+		item, ok := t.resolve(rf).(dict)
+		if !ok {
+			break
+		}
+		t.items++
+
+		title, _ := t.resolve(item["Title"]).(string)
+		e := Entry{Title: textString(title), Page: t.destPage(item)}
+		if e.Page > 0 && e.Page <= len(labels) {
+			e.Label = labels[e.Page-1]
+		}
+		e.Children = t.entries(item["First"], labels, seen, depth+1)
+		entries = append(entries, e)
+
+		obj = item["Next"]
+	}
+	return entries
+}
+
+// destPage returns the page an outline item points to, from its /Dest or
+// from a GoTo action.
+func (t *tocReader) destPage(item dict) int {
+	dest := item["Dest"]
+	if dest == nil {
+		action, ok := t.resolve(item["A"]).(dict)
+		if !ok || action["S"] != name("GoTo") {
+			return 0
+		}
+		dest = action["D"]
+	}
+
+	for depth := 0; depth < 8; depth++ {
+		switch d := t.resolve(dest).(type) {
+		case array:
+			if len(d) == 0 {
+				return 0
+			}
+			switch p := d[0].(type) {
+			case ref:
+				return t.pages[p.num]
+			case int64:
+				// remote destinations use page indexes, and some local
+				// ones do as well
+				if p >= 0 && int(p) < t.count {
+					return int(p) + 1
+				}
+			}
+			return 0
+		case dict:
+			dest = d["D"]
+		case name:
+			dests, _ := t.resolve(t.catalog["Dests"]).(dict)
+			dest = dests[d]
+		case string:
+			dest = t.namedDest(d)
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+func (t *tocReader) namedDest(key string) any {
+	if t.names == nil {
+		t.names = make(map[string]any)
+		if names, ok := t.resolve(t.catalog["Names"]).(dict); ok {
+			t.walkTree(names["Dests"], "Names", make(map[int]bool), 0, func(k, v any) {
+				if s, ok := k.(string); ok {
+					t.names[s] = v
+				}
+			})
+		}
+	}
+	return t.names[key]
+}
+
+// walkTree calls fn with every key and value of a name or number tree.
+func (t *tocReader) walkTree(obj any, leaves name, seen map[int]bool, depth int, fn func(k, v any)) {
+	if depth > maxDepth {
+		return
+	}
+	if rf, ok := obj.(ref); ok {
+		if seen[rf.num] {
+			return
+		}
+		seen[rf.num] = true
+	}
+
+	node, ok := t.resolve(obj).(dict)
+	if !ok {
+		return
+	}
+	if pairs, ok := t.resolve(node[leaves]).(array); ok {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			fn(t.resolve(pairs[i]), pairs[i+1])
+		}
+	}
+	if kids, ok := t.resolve(node["Kids"]).(array); ok {
+		for _, kid := range kids {
+			t.walkTree(kid, leaves, seen, depth+1, fn)
+		}
+	}
+}
+
+// pageLabels returns the label of every page, or nil when the document has
+// no page labels.
+func (t *tocReader) pageLabels() []string {
+	type labelRange struct {
+		start  int
+		style  name
+		prefix string
+		first  int
+	}
+
+	var ranges []labelRange
+	t.walkTree(t.catalog["PageLabels"], "Nums", make(map[int]bool), 0, func(k, v any) {
+		start, ok := k.(int64)
+		label, isDict := t.resolve(v).(dict)
+		if !ok || !isDict || start < 0 {
+			return
+		}
+		if start >= int64(t.count) {
+			return
+		}
+		r := labelRange{start: int(start), first: 1}
+		r.style, _ = label["S"].(name)
+		if prefix, ok := t.resolve(label["P"]).(string); ok {
+			r.prefix = textString(prefix)
+			if runes := []rune(r.prefix); len(runes) > maxLabelPrefix {
+				r.prefix = string(runes[:maxLabelPrefix])
+			}
+		}
+		if first, ok := label["St"].(int64); ok && first > 0 {
+			r.first = int(min(first, maxLabelStart))
+		}
+		ranges = append(ranges, r)
+	})
+	if len(ranges) == 0 || t.count == 0 {
+		return nil
+	}
+
+	// the specification asks for sorted keys, but not every file has them
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	labels := make([]string, t.count)
+	next := 0
+	var r *labelRange
+	for i := range labels {
+		for next < len(ranges) && ranges[next].start <= i {
+			r = &ranges[next]
+			next++
+		}
+		if r == nil {
+			labels[i] = strconv.Itoa(i + 1)
+			continue
+		}
+		labels[i] = r.prefix + formatLabel(r.style, r.first+i-r.start)
+	}
+	return labels
+}
+
+func formatLabel(style name, n int) string {
+	switch style {
+	case "D":
+		return strconv.Itoa(n)
+	case "R":
+		return roman(n)
+	case "r":
+		return strings.ToLower(roman(n))
+	case "A":
+		return letters(n)
+	case "a":
+		return strings.ToLower(letters(n))
+	}
+	// a label with no style is only its prefix
+	return ""
+}
+
+func roman(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	numerals := []struct {
+		value  int
+		digits string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"},
+		{100, "C"}, {90, "XC"}, {50, "L"}, {40, "XL"},
+		{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var b strings.Builder
+	for _, numeral := range numerals {
+		for n >= numeral.value {
+			b.WriteString(numeral.digits)
+			n -= numeral.value
+		}
+	}
+	return b.String()
+}
+
+// letters numbers pages A to Z, then AA to ZZ, and so on. Numbers that
+// would need more than maxLetters letters are written as digits.
+func letters(n int) string {
+	if n <= 0 || (n-1)/26+1 > maxLetters {
+		return strconv.Itoa(n)
+	}
+	letter := string(rune('A' + (n-1)%26))
+	return strings.Repeat(letter, (n-1)/26+1)
+}
+
+// pdfDocEncoding maps the bytes 0x80-0x9f of PDFDocEncoding, which differ
+// from Latin-1. 0 marks undefined codes.
+var pdfDocEncoding = [32]rune{
+	'•', '†', '‡', '…', '—', '–', 'ƒ', '⁄', '‹', '›', '−', '‰', '„', '“', '”', '‘',
+	'’', '‚', '™', 'ﬁ', 'ﬂ', 'Ł', 'Œ', 'Š', 'Ÿ', 'Ž', 'ı', 'ł', 'œ', 'š', 'ž', 0,
+}
+
+// textString decodes a PDF text string, which is UTF-16BE or UTF-8 with a
+// byte order mark and PDFDocEncoding otherwise.
+func textString(s string) string {
+	b := []byte(s)
+	var text string
+	switch {
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		text = string(utf16.Decode(units))
+	case bytes.HasPrefix(b, []byte{0xef, 0xbb, 0xbf}):
+		text = strings.ToValidUTF8(string(b[3:]), "�")
+	default:
+		runes := make([]rune, 0, len(b))
+		for _, c := range b {
+			switch {
+			case c >= 0x80 && c <= 0x9f && pdfDocEncoding[c-0x80] != 0:
+				runes = append(runes, pdfDocEncoding[c-0x80])
+			case c == 0xa0:
+				runes = append(runes, '€')
+			default:
+				runes = append(runes, rune(c))
+			}
+		}
+		text = string(runes)
+	}
+
+	// titles often carry line breaks and padding from the authoring tool
+	text = strings.Map(func(r rune) rune {
+		if r < 0x20 {
+			return ' '
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
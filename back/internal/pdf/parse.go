@@ -0,0 +1,364 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// PDF objects are decoded to these types, and to bool, int64, float64,
+// string (for PDF strings) and nil.
+type (
+	name    string
+	dict    map[name]any
+	array   []any
+	keyword string
+	ref     struct{ num, gen int }
+)
+
+// stream is a stream object whose data has not been read yet.
+type stream struct {
+	dict   dict
+	offset int64
+}
+
+// errTruncated means the object continues past the end of the buffer, so it
+// has to be parsed again from a larger one.
+var errTruncated = errors.New("object truncated")
+
+const maxDepth = 64
+
+type parser struct {
+	buf []byte
+	pos int
+	// complete is true when buf reaches the end of the file
+	complete bool
+}
+
+func (p *parser) truncated() error {
+	if p.complete {
+		return errors.New("unexpected end of file")
+	}
+	return errTruncated
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+		if c == '%' {
+			for p.pos < len(p.buf) && p.buf[p.pos] != '\n' && p.buf[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// token reads a run of regular characters, such as a number or keyword.
+func (p *parser) token() string {
+	start := p.pos
+	for p.pos < len(p.buf) && !isSpace(p.buf[p.pos]) && !isDelimiter(p.buf[p.pos]) {
+		p.pos++
+	}
+	return string(p.buf[start:p.pos])
+}
+
+func (p *parser) object(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("objects nested too deeply")
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.buf) {
+		return nil, p.truncated()
+	}
+
+	switch c := p.buf[p.pos]; {
+	case c == '/':
+		p.pos++
+		return p.name()
+	case c == '(':
+		p.pos++
+		return p.literalString()
+	case c == '<':
+		if p.pos+1 >= len(p.buf) {
+			return nil, p.truncated()
+		}
+		if p.buf[p.pos+1] == '<' {
+			p.pos += 2
+			return p.dict(depth)
+		}
+		p.pos++
+		return p.hexString()
+	case c == '[':
+		p.pos++
+		return p.array(depth)
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	case isDelimiter(c):
+		p.pos++
+		return keyword(c), nil
+	default:
+		switch t := p.token(); t {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return keyword(t), nil
+		}
+	}
+}
+
+func (p *parser) name() (name, error) {
+	var b []byte
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+		if isSpace(c) || isDelimiter(c) {
+			return name(b), nil
+		}
+		if c == '#' && p.pos+2 < len(p.buf) {
+			if v, err := strconv.ParseUint(string(p.buf[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		p.pos++
+	}
+	return "", p.truncated()
+}
+
+func (p *parser) literalString() (string, error) {
+	var b []byte
+	nesting := 0
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				return string(b), nil
+			}
+			nesting--
+		case '\\':
+			if p.pos >= len(p.buf) {
+				return "", p.truncated()
+			}
+			c = p.buf[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// a line continuation
+				if p.pos < len(p.buf) && p.buf[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.buf) && p.buf[p.pos] >= '0' && p.buf[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.buf[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return "", p.truncated()
+}
+
+func (p *parser) hexString() (string, error) {
+	var digits []byte
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+		p.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			b := make([]byte, len(digits)/2)
+			for i := range b {
+				v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				if err != nil {
+					return "", fmt.Errorf("invalid hex string: %w", err)
+				}
+				b[i] = byte(v)
+			}
+			return string(b), nil
+		}
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return "", p.truncated()
+}
+
+func (p *parser) array(depth int) (array, error) {
+	var a array
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.buf) {
+			return nil, p.truncated()
+		}
+		if p.buf[p.pos] == ']' {
+			p.pos++
+			return a, nil
+		}
+		obj, err := p.object(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, obj)
+	}
+}
+
+func (p *parser) dict(depth int) (dict, error) {
+	d := make(dict)
+	for {
+		p.skipSpace()
+		if p.pos+1 >= len(p.buf) {
+			return nil, p.truncated()
+		}
+		if p.buf[p.pos] == '>' && p.buf[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		key, err := p.object(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key is %T, not a name", key)
+		}
+		value, err := p.object(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		d[k] = value
+	}
+}
+
+// number reads an integer or real, and an indirect reference when the
+// integer is followed by a generation number and R.
+func (p *parser) number() (any, error) {
+	t := p.token()
+	if p.pos >= len(p.buf) && !p.complete {
+		return nil, errTruncated
+	}
+
+	n, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			// malformed numbers such as "--1" are read as 0, like most readers do
+			return int64(0), nil
+		}
+		return f, nil
+	}
+
+	// look ahead for "gen R"
+	save := p.pos
+	p.skipSpace()
+	if p.pos < len(p.buf) && p.buf[p.pos] >= '0' && p.buf[p.pos] <= '9' {
+		genToken := p.token()
+		p.skipSpace()
+		if gen, err := strconv.Atoi(genToken); err == nil && p.pos < len(p.buf) && p.buf[p.pos] == 'R' &&
+			(p.pos+1 == len(p.buf) || isSpace(p.buf[p.pos+1]) || isDelimiter(p.buf[p.pos+1])) {
+			p.pos++
+			return ref{int(n), gen}, nil
+		}
+	}
+	if p.pos >= len(p.buf) && !p.complete {
+		return nil, errTruncated
+	}
+	p.pos = save
+	return n, nil
+}
+
+// indirect reads "num gen obj ... endobj". For a stream, the returned
+// offset is where its data starts, relative to the buffer.
+func (p *parser) indirect() (num int, obj any, err error) {
+	p.skipSpace()
+	numToken := p.token()
+	p.skipSpace()
+	p.token()
+	p.skipSpace()
+	if kw := p.token(); kw != "obj" {
+		if p.pos >= len(p.buf) {
+			return 0, nil, p.truncated()
+		}
+		return 0, nil, errors.New("object header not found")
+	}
+	num, err = strconv.Atoi(numToken)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid object number %q", numToken)
+	}
+
+	obj, err = p.object(0)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	d, ok := obj.(dict)
+	if !ok {
+		return num, obj, nil
+	}
+	p.skipSpace()
+	if !bytes.HasPrefix(p.buf[p.pos:], []byte("stream")) {
+		if len(p.buf)-p.pos < len("stream") && !p.complete {
+			return 0, nil, errTruncated
+		}
+		return num, d, nil
+	}
+	p.pos += len("stream")
+	// the data starts after the end of the line
+	if p.pos < len(p.buf) && p.buf[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.buf) && p.buf[p.pos] == '\n' {
+		p.pos++
+	}
+	return num, &stream{dict: d, offset: int64(p.pos)}, nil
+}
@@ -0,0 +1,287 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// layout is how a fixture stores its cross-reference data.
+type layout int
+
+const (
+	classic    layout = iota // xref table and trailer
+	compressed               // xref stream, with objects in an object stream
+	broken                   // xref table with wrong offsets
+)
+
+// buildPDF writes objects, keyed by object number, as a PDF with the given
+// layout. Object 1 is the catalog. trailer is added to the trailer dict.
+func buildPDF(objects map[int]string, l layout, trailer string) []byte {
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	size := nums[len(nums)-1] + 1
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make(map[int]int)
+	write := func(num int, body string) {
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+
+	if l != compressed {
+		for _, num := range nums {
+			write(num, objects[num])
+		}
+		xref := out.Len()
+		fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", size)
+		for num := 1; num < size; num++ {
+			offset, ok := offsets[num]
+			if !ok {
+				out.WriteString("0000000000 65535 f \n")
+				continue
+			}
+			if l == broken {
+				offset += 7
+			}
+			fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+		}
+		if l == broken {
+			xref += 3
+		}
+		fmt.Fprintf(&out, "trailer\n<</Size %d/Root 1 0 R%s>>\nstartxref\n%d\n%%%%EOF\n", size, trailer, xref)
+		return out.Bytes()
+	}
+
+	// objects other than streams go into an object stream
+	var packed []int
+	var header, data strings.Builder
+	index := make(map[int]int)
+	for _, num := range nums {
+		if strings.Contains(objects[num], "stream") {
+			write(num, objects[num])
+			continue
+		}
+		index[num] = len(packed)
+		packed = append(packed, num)
+		fmt.Fprintf(&header, "%d %d ", num, data.Len())
+		data.WriteString(objects[num] + "\n")
+	}
+	objStm := size
+	body := deflate([]byte(header.String() + data.String()))
+	write(objStm, fmt.Sprintf("<</Type/ObjStm/N %d/First %d/Filter/FlateDecode/Length %d>>stream\n%s\nendstream",
+		len(packed), header.Len(), len(body), body))
+
+	xrefNum := size + 1
+	offsets[xrefNum] = out.Len()
+	var rows []byte
+	for num := 0; num < xrefNum+1; num++ {
+		row := make([]byte, 6)
+		if offset, ok := offsets[num]; ok {
+			row[0] = 1
+			binary.BigEndian.PutUint32(row[1:], uint32(offset))
+		} else if i, ok := index[num]; ok {
+			row[0] = 2
+			binary.BigEndian.PutUint32(row[1:], uint32(objStm))
+			row[5] = byte(i)
+		}
+		rows = append(rows, row...)
+	}
+	// PNG Up predictor, as most writers use for xref streams
+	var predicted []byte
+	prev := make([]byte, 6)
+	for i := 0; i < len(rows); i += 6 {
+		predicted = append(predicted, 2)
+		for j := 0; j < 6; j++ {
+			predicted = append(predicted, rows[i+j]-prev[j])
+		}
+		prev = rows[i : i+6]
+	}
+	body = deflate(predicted)
+	fmt.Fprintf(&out, "%d 0 obj\n<</Type/XRef/Size %d/W[1 4 1]/Root 1 0 R%s/Filter/FlateDecode/DecodeParms<</Predictor 12/Columns 6>>/Length %d>>stream\n%s\nendstream\nendobj\n",
+		xrefNum, xrefNum+1, trailer, len(body), body)
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", offsets[xrefNum])
+	return out.Bytes()
+}
+
+func deflate(data []byte) string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.String()
+}
+
+// bookObjects is a document of 8 pages in two page tree nodes, with a
+// nested outline using every kind of destination, and page labels for
+// front matter, the body and an appendix.
+func bookObjects() map[int]string {
+	objects := map[int]string{
+		1:  "<</Type/Catalog/Pages 2 0 R/Outlines 3 0 R/Names<</Dests 30 0 R>>/Dests 31 0 R/PageLabels<</Nums[0<</S/r>>3<</S/D>>7<</S/A/P(App-)>>]>>>>",
+		2:  "<</Type/Pages/Kids[20 0 R 21 0 R]/Count 8>>",
+		20: "<</Type/Pages/Kids[10 0 R 11 0 R 12 0 R 13 0 R]/Count 4>>",
+		21: "<</Type/Pages/Kids[14 0 R 15 0 R 16 0 R 17 0 R]/Count 4>>",
+		3:  "<</Type/Outlines/First 4 0 R/Last 6 0 R>>",
+		4:  "<</Title(Preface)/Dest[11 0 R/XYZ 0 0 0]/Next 5 0 R>>",
+		5:  "<</Title<FEFF0043006800610070007400650072002000310020D83DDCD6>/A<</S/GoTo/D(ch1)>>/Next 6 0 R/First 7 0 R>>",
+		6:  "<</Title(Appendix  \\n  A)/Dest/app/Prev 5 0 R>>",
+		7:  "<</Title(Section \\2201.1)/Dest 40 0 R/Next 8 0 R>>",
+		// points back at its sibling, so the outline loops
+		8:  "<</Title(Loop)/Dest[5]/Next 7 0 R>>",
+		30: "<</Kids[32 0 R]>>",
+		32: "<</Limits[(a)(z)]/Names[(ch1)<</D[13 0 R/Fit]>>]>>",
+		31: "<</app[17 0 R/Fit]>>",
+		40: "[14 0 R/Fit]",
+		// the Length is wrong, as it often is
+		50: "<</Length 99>>stream\nBT ET\nendstream",
+	}
+	for num := 10; num <= 17; num++ {
+		objects[num] = "<</Type/Page/Parent 2 0 R/MediaBox[0 0 100 100]/Contents 50 0 R>>"
+	}
+	return objects
+}
+
+func writeFixture(t testing.TB, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadTOC(t *testing.T) {
+	want := TOC{
+		Pages: 8,
+		Outline: []Entry{
+			{Title: "Preface", Page: 2, Label: "ii"},
+			{Title: "Chapter 1 📖", Page: 4, Label: "1", Children: []Entry{
+				{Title: "Section ’1.1", Page: 5, Label: "2"},
+				{Title: "Loop", Page: 6, Label: "3"},
+			}},
+			{Title: "Appendix A", Page: 8, Label: "App-A"},
+		},
+		PageLabels: []string{"i", "ii", "iii", "1", "2", "3", "4", "App-A"},
+	}
+
+	for name, l := range map[string]layout{"classic": classic, "compressed": compressed, "broken": broken} {
+		t.Run(name, func(t *testing.T) {
+			toc, err := ReadTOC(writeFixture(t, buildPDF(bookObjects(), l, "")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(toc, want) {
+				t.Errorf("got %+v, want %+v", toc, want)
+			}
+		})
+	}
+}
+
+func TestReadTOCWithoutOutline(t *testing.T) {
+	objects := map[int]string{
+		1: "<</Type/Catalog/Pages 2 0 R>>",
+		2: "<</Type/Pages/Kids[3 0 R]/Count 1>>",
+		3: "<</Type/Page/Parent 2 0 R>>",
+	}
+	toc, err := ReadTOC(writeFixture(t, buildPDF(objects, classic, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TOC{Pages: 1, Outline: []Entry{}}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("got %+v, want %+v", toc, want)
+	}
+}
+
+func TestReadTOCEncrypted(t *testing.T) {
+	path := writeFixture(t, buildPDF(bookObjects(), classic, "/Encrypt<</Filter/Standard>>"))
+	if _, err := ReadTOC(path); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("got %v, want ErrEncrypted", err)
+	}
+}
+
+func TestReadTOCNotPDF(t *testing.T) {
+	path := writeFixture(t, bytes.Repeat([]byte("hello world "), 100))
+	if _, err := ReadTOC(path); err == nil {
+		t.Error("got no error for a file that is not a PDF")
+	}
+}
+
+func TestPageLabelsBounded(t *testing.T) {
+	objects := bookObjects()
+	objects[1] = "<</Type/Catalog/Pages 2 0 R/PageLabels<</Nums[5<</S/A/St 1000000000000>>0<</S/R/P(" +
+		strings.Repeat("x", 1000) + ")>>]>>>>"
+
+	toc, err := ReadTOC(writeFixture(t, buildPDF(objects, classic, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := toc.PageLabels[0], strings.Repeat("x", maxLabelPrefix)+"I"; got != want {
+		t.Errorf("label of page 1 = %q, want %q", got, want)
+	}
+	// the unsorted ranges still apply in order of their first page
+	if got, want := toc.PageLabels[5], "1000000"; got != want {
+		t.Errorf("label of page 6 = %q, want %q", got, want)
+	}
+}
+
+func TestHostileStreams(t *testing.T) {
+	tests := map[string]map[int]string{
+		"huge predictor columns": {
+			60: "<</Filter/FlateDecode/DecodeParms<</Predictor 12/Columns 9223372036854775807/Colors 9223372036854775807>>/Length 8>>stream\n" +
+				deflate([]byte("abcdefgh")) + "\nendstream",
+		},
+		"negative object stream offset": {
+			60: fmt.Sprintf("<</Type/ObjStm/N 1/First 6/Length %d>>stream\n%s\nendstream", len("9 -50 <<>>"), "9 -50 <<>>"),
+		},
+	}
+
+	for name, extra := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects := bookObjects()
+			for num, body := range extra {
+				objects[num] = body
+			}
+			path := writeFixture(t, buildPDF(objects, classic, ""))
+
+			r, err := open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if s, ok := r.object(60).(*stream); ok {
+				r.streamData(s)
+			}
+			// object 9 is only in the broken object stream
+			r.xref[9] = xrefEntry{stream: 60}
+			if obj := r.object(9); obj != nil {
+				t.Errorf("got %v from a negative offset", obj)
+			}
+		})
+	}
+}
+
+// FuzzReadTOC checks that damaged and hostile files return an error instead
+// of panicking or running out of memory.
+func FuzzReadTOC(f *testing.F) {
+	for _, l := range []layout{classic, compressed, broken} {
+		f.Add(buildPDF(bookObjects(), l, ""))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := writeFixture(t, data)
+		ReadTOC(path)
+	})
+}
@@ -0,0 +1,643 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// ErrEncrypted is returned for encrypted documents, whose strings cannot be
+// read without decrypting them.
+var ErrEncrypted = errors.New("encrypted PDFs are not supported")
+
+const (
+	// maxObjectSize bounds the buffer an object is parsed from, not streams.
+	maxObjectSize = 16 << 20
+	maxStreamSize = 64 << 20
+)
+
+type xrefEntry struct {
+	// offset of the object in the file, or 0 when it is compressed
+	offset int64
+	// the object stream holding a compressed object, and its index there
+	stream int
+	index  int
+}
+
+// reader resolves the objects of a document.
+type reader struct {
+	f       *os.File
+	size    int64
+	xref    map[int]xrefEntry
+	trailer dict
+	objects map[int]any
+	// decoded object streams
+	objStreams map[int]*objStream
+}
+
+type objStream struct {
+	data    []byte
+	offsets map[int]int
+}
+
+func open(path string) (*reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &reader{
+		f:          f,
+		size:       info.Size(),
+		xref:       make(map[int]xrefEntry),
+		objects:    make(map[int]any),
+		objStreams: make(map[int]*objStream),
+	}
+
+	if err := r.loadXref(); err != nil || r.trailer["Root"] == nil {
+		// damaged files are read by scanning for their objects
+		r.xref = make(map[int]xrefEntry)
+		r.trailer = nil
+		if err := r.reconstruct(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if r.trailer["Encrypt"] != nil {
+		f.Close()
+		return nil, ErrEncrypted
+	}
+	return r, nil
+}
+
+func (r *reader) Close() error {
+	return r.f.Close()
+}
+
+// parseAt parses with parse from offset, growing the buffer until the
+// object fits.
+func (r *reader) parseAt(offset int64, parse func(p *parser) error) (*parser, error) {
+	if offset < 0 || offset >= r.size {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	for size := int64(4096); ; size *= 4 {
+		if offset+size > r.size {
+			size = r.size - offset
+		}
+		buf := make([]byte, size)
+		if _, err := r.f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		p := &parser{buf: buf, complete: offset+size == r.size}
+		err := parse(p)
+		if err == errTruncated && size < maxObjectSize {
+			continue
+		}
+		return p, err
+	}
+}
+
+func (r *reader) loadXref() error {
+	tailSize := min(r.size, 2048)
+	tail := make([]byte, tailSize)
+	if _, err := r.f.ReadAt(tail, r.size-tailSize); err != nil && err != io.EOF {
+		return err
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i == -1 {
+		return errors.New("startxref not found")
+	}
+	p := &parser{buf: tail[i+len("startxref"):], complete: true}
+	p.skipSpace()
+	offset, err := strconv.ParseInt(p.token(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid startxref: %w", err)
+	}
+
+	// follow the chain of updates from the newest section, whose entries
+	// take precedence
+	seen := make(map[int64]bool)
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := r.xrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+		}
+		// hybrid files keep compressed objects in an extra xref stream
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := r.xrefSection(stm); err != nil {
+				return err
+			}
+		}
+		prev, _ := trailer["Prev"].(int64)
+		offset = prev
+	}
+	return nil
+}
+
+// xrefSection reads a cross-reference table or stream and returns its trailer.
+func (r *reader) xrefSection(offset int64) (dict, error) {
+	head := make([]byte, 4)
+	if _, err := r.f.ReadAt(head, offset); err != nil {
+		return nil, err
+	}
+	if string(head) == "xref" {
+		return r.xrefTable(offset)
+	}
+	return r.xrefStream(offset)
+}
+
+func (r *reader) xrefTable(offset int64) (dict, error) {
+	var trailer dict
+	_, err := r.parseAt(offset+4, func(p *parser) error {
+		for {
+			p.skipSpace()
+			start := p.token()
+			if start == "trailer" {
+				obj, err := p.object(0)
+				if err != nil {
+					return err
+				}
+				d, ok := obj.(dict)
+				if !ok {
+					return errors.New("invalid trailer")
+				}
+				trailer = d
+				return nil
+			}
+			p.skipSpace()
+			count := p.token()
+			first, err1 := strconv.Atoi(start)
+			n, err2 := strconv.Atoi(count)
+			if err1 != nil || err2 != nil {
+				if p.pos >= len(p.buf) {
+					return p.truncated()
+				}
+				return errors.New("invalid xref subsection")
+			}
+			for i := 0; i < n; i++ {
+				p.skipSpace()
+				off := p.token()
+				p.skipSpace()
+				p.token()
+				p.skipSpace()
+				kind := p.token()
+				if kind == "" {
+					return p.truncated()
+				}
+				num := first + i
+				if _, ok := r.xref[num]; ok {
+					continue
+				}
+				if kind == "n" {
+					o, _ := strconv.ParseInt(off, 10, 64)
+					r.xref[num] = xrefEntry{offset: o}
+				} else {
+					// free entries hide older versions of the object
+					r.xref[num] = xrefEntry{}
+				}
+			}
+		}
+	})
+	return trailer, err
+}
+
+func (r *reader) xrefStream(offset int64) (dict, error) {
+	var s *stream
+	_, err := r.parseAt(offset, func(p *parser) error {
+		_, obj, err := p.indirect()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if s, ok = obj.(*stream); !ok {
+			return errors.New("xref stream not found")
+		}
+		s.offset += offset
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.streamData(s)
+	if err != nil {
+		return nil, err
+	}
+
+	w, _ := s.dict["W"].(array)
+	if len(w) != 3 {
+		return nil, errors.New("invalid xref stream widths")
+	}
+	widths := make([]int, 3)
+	rowSize := 0
+	for i := range widths {
+		v, _ := w[i].(int64)
+		if v < 0 || v > 8 {
+			return nil, errors.New("invalid xref stream widths")
+		}
+		widths[i] = int(v)
+		rowSize += widths[i]
+	}
+	if rowSize == 0 {
+		return nil, errors.New("invalid xref stream widths")
+	}
+
+	index, _ := s.dict["Index"].(array)
+	if index == nil {
+		size, _ := s.dict["Size"].(int64)
+		index = array{int64(0), size}
+	}
+
+	pos := 0
+	field := func(width int, def int64) int64 {
+		if width == 0 {
+			return def
+		}
+		var v int64
+		for i := 0; i < width; i++ {
+			v = v<<8 | int64(data[pos+i])
+		}
+		pos += width
+		return v
+	}
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := index[i].(int64)
+		count, _ := index[i+1].(int64)
+		for j := int64(0); j < count; j++ {
+			if pos+rowSize > len(data) {
+				return s.dict, nil
+			}
+			kind := field(widths[0], 1)
+			a := field(widths[1], 0)
+			b := field(widths[2], 0)
+			num := int(first + j)
+			if _, ok := r.xref[num]; ok {
+				continue
+			}
+			switch kind {
+			case 1:
+				r.xref[num] = xrefEntry{offset: a}
+			case 2:
+				r.xref[num] = xrefEntry{stream: int(a), index: int(b)}
+			default:
+				r.xref[num] = xrefEntry{}
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+var objHeader = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+\d+[ \t\r\n\f\x00]+obj\b`)
+
+// reconstruct rebuilds the cross-reference table by scanning the file for
+// object headers, for documents whose xref is missing or wrong.
+func (r *reader) reconstruct() error {
+	const chunkSize = 1 << 20
+	const overlap = 64
+
+	var trailerOffset int64 = -1
+	buf := make([]byte, chunkSize+overlap)
+	for offset := int64(0); offset < r.size; offset += chunkSize {
+		n, err := r.f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		chunk := buf[:n]
+		for _, m := range objHeader.FindAllSubmatchIndex(chunk, -1) {
+			// matches in the overlap are found again in the next chunk
+			if m[0] >= chunkSize {
+				continue
+			}
+			// the number must not be the end of a longer token
+			if m[0] > 0 && !isSpace(chunk[m[0]-1]) && !isDelimiter(chunk[m[0]-1]) {
+				continue
+			}
+			num, _ := strconv.Atoi(string(chunk[m[2]:m[3]]))
+			r.xref[num] = xrefEntry{offset: offset + int64(m[0])}
+		}
+		if i := bytes.LastIndex(chunk[:min(len(chunk), chunkSize)], []byte("trailer")); i != -1 {
+			trailerOffset = offset + int64(i) + int64(len("trailer"))
+		}
+	}
+
+	if trailerOffset != -1 {
+		r.parseAt(trailerOffset, func(p *parser) error {
+			obj, err := p.object(0)
+			if d, ok := obj.(dict); ok {
+				r.trailer = d
+			}
+			return err
+		})
+	}
+	if r.trailer == nil {
+		r.trailer = make(dict)
+	}
+
+	if r.trailer["Root"] == nil {
+		// files with xref streams have no trailer, so look for the catalog
+		for num := range r.xref {
+			d, ok := r.resolve(ref{num: num}).(dict)
+			if ok && d["Type"] == name("Catalog") {
+				r.trailer["Root"] = ref{num: num}
+				break
+			}
+		}
+	}
+	if r.trailer["Root"] == nil {
+		return errors.New("document catalog not found")
+	}
+	return nil
+}
+
+// resolve follows indirect references. Objects that cannot be read resolve
+// to nil, as the specification asks for missing objects.
+func (r *reader) resolve(obj any) any {
+	for depth := 0; depth < maxDepth; depth++ {
+		rf, ok := obj.(ref)
+		if !ok {
+			return obj
+		}
+		obj = r.object(rf.num)
+	}
+	return nil
+}
+
+func (r *reader) object(num int) any {
+	if obj, ok := r.objects[num]; ok {
+		return obj
+	}
+	// guard against objects that refer to themselves while loading
+	r.objects[num] = nil
+
+	entry, ok := r.xref[num]
+	var obj any
+	switch {
+	case !ok:
+	case entry.offset > 0:
+		r.parseAt(entry.offset, func(p *parser) error {
+			n, o, err := p.indirect()
+			if err == nil && n == num {
+				if s, ok := o.(*stream); ok {
+					s.offset += entry.offset
+				}
+				obj = o
+			}
+			return err
+		})
+	case entry.stream > 0:
+		obj = r.compressedObject(entry.stream, entry.index, num)
+	}
+
+	r.objects[num] = obj
+	return obj
+}
+
+func (r *reader) compressedObject(streamNum, index, num int) any {
+	os, ok := r.objStreams[streamNum]
+	if !ok {
+		os = r.loadObjStream(streamNum)
+		r.objStreams[streamNum] = os
+	}
+	if os == nil {
+		return nil
+	}
+
+	offset, ok := os.offsets[num]
+	if !ok || offset < 0 || offset >= len(os.data) {
+		return nil
+	}
+	p := &parser{buf: os.data[offset:], complete: true}
+	obj, err := p.object(0)
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+func (r *reader) loadObjStream(num int) *objStream {
+	s, ok := r.object(num).(*stream)
+	if !ok {
+		return nil
+	}
+	data, err := r.streamData(s)
+	if err != nil {
+		return nil
+	}
+
+	n, _ := s.dict["N"].(int64)
+	first, _ := s.dict["First"].(int64)
+	if first <= 0 || first > int64(len(data)) {
+		return nil
+	}
+
+	os := &objStream{data: data[first:], offsets: make(map[int]int)}
+	p := &parser{buf: data[:first], complete: true}
+	for i := int64(0); i < n; i++ {
+		p.skipSpace()
+		objNum, err1 := strconv.Atoi(p.token())
+		p.skipSpace()
+		objOffset, err2 := strconv.Atoi(p.token())
+		if err1 != nil || err2 != nil || objOffset < 0 {
+			break
+		}
+		os.offsets[objNum] = objOffset
+	}
+	return os
+}
+
+// streamData reads and decodes the data of a stream.
+func (r *reader) streamData(s *stream) ([]byte, error) {
+	length, ok := r.resolve(s.dict["Length"]).(int64)
+	if !ok || length < 0 || s.offset+length > r.size {
+		length = -1
+	}
+
+	var raw []byte
+	if length >= 0 {
+		raw = make([]byte, length)
+		if _, err := r.f.ReadAt(raw, s.offset); err != nil {
+			return nil, err
+		}
+	}
+	// a wrong Length is common, so check that the stream really ends there
+	if length < 0 || !endsStream(r.f, s.offset+length) {
+		var err error
+		if raw, err = r.scanStream(s.offset); err != nil {
+			return nil, err
+		}
+	}
+
+	return decode(raw, s.dict)
+}
+
+func endsStream(f *os.File, offset int64) bool {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, offset)
+	return bytes.Contains(buf[:n], []byte("endstream"))
+}
+
+// scanStream reads a stream up to its endstream keyword.
+func (r *reader) scanStream(offset int64) ([]byte, error) {
+	for size := int64(64 << 10); ; size *= 4 {
+		if offset+size > r.size {
+			size = r.size - offset
+		}
+		buf := make([]byte, size)
+		n, err := r.f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = buf[:n]
+		if i := bytes.Index(buf, []byte("endstream")); i != -1 {
+			return bytes.TrimRight(buf[:i], "\r\n"), nil
+		}
+		if offset+size >= r.size || size >= maxStreamSize {
+			return nil, errors.New("endstream not found")
+		}
+	}
+}
+
+func decode(data []byte, d dict) ([]byte, error) {
+	filters := array{}
+	switch f := d["Filter"].(type) {
+	case name:
+		filters = array{f}
+	case array:
+		filters = f
+	}
+	var params array
+	switch p := d["DecodeParms"].(type) {
+	case dict:
+		params = array{p}
+	case array:
+		params = p
+	}
+
+	for i, f := range filters {
+		if f != name("FlateDecode") && f != name("Fl") {
+			return nil, fmt.Errorf("unsupported filter %v", f)
+		}
+		var err error
+		if data, err = inflate(data); err != nil {
+			return nil, err
+		}
+		if i < len(params) {
+			if p, ok := params[i].(dict); ok {
+				if data, err = unpredict(data, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(zr, maxStreamSize))
+	// streams missing their checksum are still usable
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unpredict reverses the PNG predictors used by xref and object streams.
+func unpredict(data []byte, p dict) ([]byte, error) {
+	predictor, _ := p["Predictor"].(int64)
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("unsupported predictor %d", predictor)
+		}
+		return data, nil
+	}
+
+	columns := int64(1)
+	if c, ok := p["Columns"].(int64); ok && c > 0 {
+		columns = c
+	}
+	colors := int64(1)
+	if c, ok := p["Colors"].(int64); ok && c > 0 {
+		colors = c
+	}
+	bits := int64(8)
+	if b, ok := p["BitsPerComponent"].(int64); ok && b > 0 {
+		bits = b
+	}
+	// the values come from the file, so a row must fit in the data before
+	// they are multiplied
+	if colors > 32 || bits > 16 || columns > int64(len(data)) {
+		return nil, errors.New("invalid predictor parameters")
+	}
+	bpp := int(max(1, (colors*bits+7)/8))
+	rowSize := int((columns*colors*bits + 7) / 8)
+	if rowSize >= len(data) {
+		return nil, errors.New("invalid predictor parameters")
+	}
+
+	var out []byte
+	prev := make([]byte, rowSize)
+	for len(data) >= rowSize+1 {
+		kind, row := data[0], append([]byte{}, data[1:rowSize+1]...)
+		data = data[rowSize+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,279 @@
+// Package render caches rendered book pages on disk.
+package render
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheDir   = "/cache/pages"
+	tempPrefix = ".render-"
+)
+
+var maxBytes int64
+
+func init() {
+	maxBytes = int64(getEnvInt("RENDER_CACHE_MB", 1024)) << 20
+}
+
+func getEnvInt(key string, def int) int {
+	if val := os.Getenv(key); val != "" {
+		if v, err := strconv.Atoi(val); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+// Key identifies a rendered page. Renders are keyed by the fingerprint of
+// the book rather than its path, so they survive moves. The fingerprint only
+// covers the size, head and tail of the file, so the modification time is
+// part of the key as well to notice edits in between.
+type Key struct {
+	Fingerprint string
+	ModTime     int64
+	Page        int
+	// DPI is 0 for pages that are images already, such as comic pages.
+	DPI int
+	// Width and Height are the bounds the page was scaled to fit, 0 if none.
+	Width  int
+	Height int
+	// Format is the file extension of the output, such as "png".
+	Format string
+}
+
+func (k Key) path() string {
+	name := fmt.Sprintf("%s-%d-p%d-r%d", k.Fingerprint, k.ModTime, k.Page, k.DPI)
+	if k.Width > 0 || k.Height > 0 {
+		name += fmt.Sprintf("-%dx%d", k.Width, k.Height)
+	}
+	return filepath.Join(cacheDir, k.Fingerprint[:2], name+"."+k.Format)
+}
+
+type file struct {
+	path string
+	size int64
+}
+
+// call is a render in progress, shared by the requests for the same page.
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+var (
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	order     = list.New() // most recently used first
+	files     = make(map[string]*list.Element)
+	total     int64
+	rendering = make(map[string]*call)
+)
+
+// Open returns the cached render for key, calling render on the render queue
+// to produce it on a miss. render must write the page to outPath and stop
+// when ctx is done. Concurrent requests for the same key wait for a single
+// render.
+//
+// The file is returned open so that it can still be served if it is evicted
+// in the meantime.
+func Open(ctx context.Context, key Key, priority Priority, render func(ctx context.Context, outPath string) error) (*os.File, error) {
+	loadOnce.Do(load)
+	path := key.path()
+
+	for attempt := 0; ; attempt++ {
+		if f, err := os.Open(path); err == nil {
+			touch(path)
+			if attempt == 0 {
+				countLookup(true)
+			}
+			return f, nil
+		}
+		if attempt == 0 {
+			countLookup(false)
+		}
+
+		mu.Lock()
+		if c, ok := rendering[path]; ok {
+			mu.Unlock()
+			if priority == High {
+				promote(path)
+			}
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if c.err != nil && !canceled(c.err) {
+				return nil, c.err
+			}
+			// the render finished, or was canceled by the request that
+			// started it, so the next loop opens or renders the file
+			continue
+		}
+		c := &call{done: make(chan struct{})}
+		rendering[path] = c
+		mu.Unlock()
+
+		c.err = create(path, func(outPath string) error {
+			return schedule(ctx, path, priority, func(ctx context.Context) error {
+				return render(ctx, outPath)
+			})
+		})
+
+		mu.Lock()
+		delete(rendering, path)
+		mu.Unlock()
+		close(c.done)
+
+		if c.err != nil {
+			return nil, c.err
+		}
+	}
+}
+
+func canceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func countLookup(hit bool) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if hit {
+		metrics.CacheHits++
+	} else {
+		metrics.CacheMisses++
+	}
+}
+
+// create renders into a temporary file and renames it into place, so that a
+// partly written page is never served.
+func create(path string, render func(outPath string) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*"+filepath.Ext(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if err := render(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err == nil && info.Size() == 0 {
+		// gs exits cleanly for pages past the end without writing anything
+		err = fmt.Errorf("render produced no output")
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to store render: %w", err)
+	}
+
+	mu.Lock()
+	add(path, info.Size())
+	evict()
+	mu.Unlock()
+	return nil
+}
+
+// touch marks a render as used. The modification time is updated as well,
+// so that the order is kept across restarts.
+func touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if el, ok := files[path]; ok {
+		order.MoveToFront(el)
+	}
+}
+
+// add records a file in the cache. mu must be held.
+func add(path string, size int64) {
+	if el, ok := files[path]; ok {
+		total -= el.Value.(*file).size
+		order.Remove(el)
+	}
+	files[path] = order.PushFront(&file{path, size})
+	total += size
+}
+
+// evict removes the least recently used renders until the cache fits in
+// RENDER_CACHE_MB. mu must be held.
+func evict() {
+	for total > maxBytes && order.Len() > 0 {
+		oldest := order.Back()
+		f := oldest.Value.(*file)
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to evict %s: %v", f.path, err)
+		}
+		order.Remove(oldest)
+		delete(files, f.path)
+		total -= f.size
+	}
+}
+
+// load picks up the renders kept from earlier runs, ordered by their last
+// use, and removes temporary files left by an interrupted render.
+func load() {
+	var found []file
+	modTimes := make(map[string]time.Time)
+
+	filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), tempPrefix) {
+			os.Remove(path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		found = append(found, file{path, info.Size()})
+		modTimes[path] = info.ModTime()
+		return nil
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		return modTimes[found[i].path].Before(modTimes[found[j].path])
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, f := range found {
+		add(f.path, f.size)
+	}
+	evict()
+}
+
+// Cached reports whether key has been rendered, without marking it as used.
+func Cached(key Key) bool {
+	_, err := os.Stat(key.path())
+	return err == nil
+}
@@ -0,0 +1,174 @@
+package render
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority orders the renders waiting for a slot.
+type Priority int
+
+const (
+	// Low is for pages rendered ahead of the reader.
+	Low Priority = iota
+	// High is for the page being viewed.
+	High
+)
+
+var workers int
+
+func init() {
+	workers = getEnvInt("RENDER_WORKERS", 2)
+	if workers < 1 {
+		workers = 1
+	}
+}
+
+type waiter struct {
+	path     string
+	priority Priority
+	ready    chan struct{}
+	el       *list.Element
+}
+
+// Metrics describes the render queue and cache since the server started.
+type Metrics struct {
+	Workers        int   `json:"workers"`
+	Running        int   `json:"running"`
+	Queued         int   `json:"queued"`
+	QueuedPrefetch int   `json:"queuedPrefetch"`
+	Completed      int64 `json:"completed"`
+	Failed         int64 `json:"failed"`
+	Canceled       int64 `json:"canceled"`
+	CacheHits      int64 `json:"cacheHits"`
+	CacheMisses    int64 `json:"cacheMisses"`
+	CacheBytes     int64 `json:"cacheBytes"`
+	AvgWaitMs      int64 `json:"avgWaitMs"`
+	AvgRenderMs    int64 `json:"avgRenderMs"`
+}
+
+var (
+	queueMu sync.Mutex
+	running int
+	// waiting renders by priority, oldest first
+	queues = [...]*list.List{Low: list.New(), High: list.New()}
+
+	metrics     Metrics
+	totalWait   time.Duration
+	totalRender time.Duration
+)
+
+// GetMetrics returns a snapshot of the render queue and cache.
+func GetMetrics() Metrics {
+	queueMu.Lock()
+	m := metrics
+	m.Workers = workers
+	m.Running = running
+	m.Queued = queues[High].Len()
+	m.QueuedPrefetch = queues[Low].Len()
+	if m.Completed > 0 {
+		m.AvgWaitMs = (totalWait / time.Duration(m.Completed)).Milliseconds()
+		m.AvgRenderMs = (totalRender / time.Duration(m.Completed)).Milliseconds()
+	}
+	queueMu.Unlock()
+
+	mu.Lock()
+	m.CacheBytes = total
+	mu.Unlock()
+
+	return m
+}
+
+// schedule runs job once one of the RENDER_WORKERS slots is free. Waiting
+// High renders start before any Low one. ctx cancels the render both while
+// it waits and while it runs.
+func schedule(ctx context.Context, path string, priority Priority, job func(ctx context.Context) error) error {
+	start := time.Now()
+	if err := acquire(ctx, path, priority); err != nil {
+		queueMu.Lock()
+		metrics.Canceled++
+		queueMu.Unlock()
+		return err
+	}
+	waited := time.Since(start)
+
+	err := job(ctx)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		// a killed process reports its signal rather than the cancellation
+		err = ctxErr
+	}
+
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	release()
+	switch {
+	case err == nil:
+		metrics.Completed++
+		totalWait += waited
+		totalRender += time.Since(start) - waited
+	case ctx.Err() != nil:
+		metrics.Canceled++
+	default:
+		metrics.Failed++
+	}
+	return err
+}
+
+func acquire(ctx context.Context, path string, priority Priority) error {
+	queueMu.Lock()
+	if running < workers && queues[High].Len() == 0 && queues[Low].Len() == 0 {
+		running++
+		queueMu.Unlock()
+		return nil
+	}
+	w := &waiter{path: path, priority: priority, ready: make(chan struct{})}
+	w.el = queues[priority].PushBack(w)
+	queueMu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		queueMu.Lock()
+		defer queueMu.Unlock()
+		select {
+		case <-w.ready:
+			// the slot was handed over just now, so pass it on
+			release()
+		default:
+			queues[w.priority].Remove(w.el)
+		}
+		return ctx.Err()
+	}
+}
+
+// release hands the slot to the next waiting render. queueMu must be held.
+func release() {
+	for _, priority := range []Priority{High, Low} {
+		if el := queues[priority].Front(); el != nil {
+			queues[priority].Remove(el)
+			close(el.Value.(*waiter).ready)
+			return
+		}
+	}
+	running--
+}
+
+// promote moves a waiting render of path ahead of the prefetches, for when
+// the reader turns to a page that was queued as a prefetch.
+func promote(path string) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	for el := queues[Low].Front(); el != nil; el = el.Next() {
+		w := el.Value.(*waiter)
+		if w.path == path {
+			queues[Low].Remove(el)
+			w.priority = High
+			w.el = queues[High].PushBack(w)
+			return
+		}
+	}
+}
@@ -3,53 +3,117 @@ package scan
 import (
 	"back/database"
 	"back/internal/cover"
+	"back/internal/fingerprint"
+	"back/internal/library"
 	"back/internal/meta"
-	"database/sql"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
 )
 
-func scanAdd(path string, bookDB, keywordDB *sql.DB) error {
-	trimmed := strings.TrimPrefix(path, "/book")
-	ext := filepath.Ext(trimmed)
-	base := strings.TrimSuffix(trimmed, ext)
-	coverPath := "/cache/cover" + base + ".webp"
+// prepareAdd extracts the cover and metadata of a new book. The database is
+// only written by the returned writeFunc, so it can run on the worker pool.
+func prepareAdd(path string) (writeFunc, error) {
+	coverPath := coverPathFor(path)
 	bookType := detectBookType(path)
 
-	err := cover.ExtractCover(path, coverPath, bookType)
+	var coverErr error
+	err := extractCover(path, coverPath, bookType)
 	if err != nil {
 		fmt.Println(err)
+		coverErr = &stageError{StageCover, err}
 		coverPath = ""
 	}
 
-	title, keywords, last_modded, err := meta.ExtractMeta(path, bookType)
+	m, err := extractMeta(path, bookType)
 	if err != nil {
-		return err
+		return nil, &stageError{StageMeta, err}
+	}
+
+	size, fp, err := fingerprint.Compute(path)
+	if err != nil {
+		return nil, &stageError{StageFingerprint, err}
 	}
 
 	book := database.BookData{
 		Path:            path,
 		CoverPath:       coverPath,
 		Type:            bookType,
-		Title:           title,
+		Title:           m.Title,
 		AddedTime:       time.Now().Unix(),
-		LastModded:      last_modded,
+		LastModded:      m.ModTime,
 		LastOpened:      0,
 		CurrentPosition: "",
 		Progress:        0.0,
+		Size:            size,
+		Fingerprint:     fp,
 	}
-	database.AddBook(bookDB, book)
 
-	for _, keyword := range keywords {
-		err = database.AddKeyword(keywordDB, path, keyword)
-		if err != nil {
+	return func(bookTx, keywordTx database.Querier) error {
+		// every stage ran again, so errors from earlier scans are replaced by
+		// the outcome of this one
+		if err := database.DeleteScanErrorsByPath(bookTx, path); err != nil {
+			return err
+		}
+		if err := database.AddBook(bookTx, book); err != nil {
 			return err
 		}
+		if err := database.UpdateBookCover(bookTx, path, coverPath, cover.Version(bookType)); err != nil {
+			return err
+		}
+		if err := storeMeta(bookTx, keywordTx, path, m); err != nil {
+			return err
+		}
+
+		if coverErr != nil {
+			return database.AddScanError(bookTx, newScanError(path, coverErr))
+		}
+
+		return nil
+	}, nil
+}
+
+// withMeta copies the metadata fields other than the title into book.
+func withMeta(book database.BookData, m meta.Meta) database.BookData {
+	book.Author = m.Author()
+	book.Series = m.Series
+	book.SeriesNumber = m.SeriesNumber
+	book.SeriesIndex = nil
+	if index, ok := meta.SeriesIndex(m.SeriesNumber); ok {
+		book.SeriesIndex = &index
 	}
+	book.Publisher = m.Publisher
+	book.Language = m.Language
+	book.Direction = m.Direction
+	book.Description = m.Description
+	book.Published = m.Published
+	book.Pages = m.Pages
+	book.Rating = m.Rating
+	book.MetaVersion = meta.Version
+
+	book.Contributors = nil
+	for _, c := range m.Contributors {
+		book.Contributors = append(book.Contributors, database.Contributor{
+			Name:          c.Name,
+			SortName:      c.SortName,
+			SortNameGiven: c.SortNameGiven,
+			Role:          c.Role,
+		})
+	}
+	book.Identifiers = nil
+	for _, id := range m.Identifiers {
+		book.Identifiers = append(book.Identifiers, database.Identifier{Scheme: id.Scheme, Value: id.Value})
+	}
+
+	return book
+}
 
-	return nil
+func coverPathFor(path string) string {
+	apiPath := library.ToAPI(path)
+	ext := filepath.Ext(apiPath)
+	base := strings.TrimSuffix(apiPath, ext)
+	return "/cache/covers" + base + ".webp"
 }
 
 func detectBookType(path string) string {
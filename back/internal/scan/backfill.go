@@ -0,0 +1,189 @@
+package scan
+
+import (
+	"back/database"
+	"back/internal/cover"
+	"back/internal/fingerprint"
+	"back/internal/meta"
+	"database/sql"
+	"fmt"
+)
+
+// backfillFingerprints fingerprints the books below root that were stored
+// by older versions, so that they can be recognised when they are moved.
+func backfillFingerprints(root string, bookDB, keywordDB *sql.DB) error {
+	paths, err := database.GetPathsWithoutFingerprint(bookDB, root)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	updateStatus(func(s *Status) {
+		s.Phase = PhaseBackfilling
+		s.BackfilledTotal += len(paths)
+	})
+	prepare := func(path string) (writeFunc, error) {
+		return prepareFingerprintBackfill(path), nil
+	}
+	runPool(paths, prepare, bookDB, keywordDB, func(path string, err error) {
+		if err != nil {
+			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
+		}
+		updateStatus(func(s *Status) { s.Backfilled++ })
+	})
+
+	return nil
+}
+
+func prepareFingerprintBackfill(path string) writeFunc {
+	size, fp, err := fingerprint.Compute(path)
+	if err != nil {
+		fmt.Println(err)
+		fpErr := &stageError{StageFingerprint, err}
+		return func(bookTx, keywordTx database.Querier) error {
+			return database.AddScanError(bookTx, newScanError(path, fpErr))
+		}
+	}
+
+	return func(bookTx, keywordTx database.Querier) error {
+		if err := database.DeleteScanError(bookTx, path, StageFingerprint); err != nil {
+			return err
+		}
+		return database.UpdateBookFingerprint(bookTx, path, size, fp)
+	}
+}
+
+// backfillMeta extracts the metadata of the books below root that were
+// scanned by an older version of the extractors again. Only the metadata is
+// extracted, the cover and the modification time are left alone. A book that
+// fails is recorded in the scan errors and not retried until it changes.
+func backfillMeta(root string, bookDB, keywordDB *sql.DB) error {
+	books, err := database.GetPathsWithOldMeta(bookDB, meta.Version, root)
+	if err != nil {
+		return err
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	types := make(map[string]string, len(books))
+	paths := make([]string, len(books))
+	for i, book := range books {
+		types[book.Path] = book.Type
+		paths[i] = book.Path
+	}
+
+	updateStatus(func(s *Status) {
+		s.Phase = PhaseBackfilling
+		s.BackfilledTotal += len(paths)
+	})
+	prepare := func(path string) (writeFunc, error) {
+		return prepareBackfill(path, types[path]), nil
+	}
+	runPool(paths, prepare, bookDB, keywordDB, func(path string, err error) {
+		if err != nil {
+			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
+		}
+		updateStatus(func(s *Status) { s.Backfilled++ })
+	})
+
+	return nil
+}
+
+func prepareBackfill(path, bookType string) writeFunc {
+	m, err := extractMeta(path, bookType)
+	if err != nil {
+		fmt.Println(err)
+		metaErr := &stageError{StageMeta, err}
+		return func(bookTx, keywordTx database.Querier) error {
+			if err := database.UpdateBookMetaVersion(bookTx, path, meta.Version); err != nil {
+				return err
+			}
+			return database.AddScanError(bookTx, newScanError(path, metaErr))
+		}
+	}
+
+	return func(bookTx, keywordTx database.Querier) error {
+		if err := database.DeleteScanError(bookTx, path, StageMeta); err != nil {
+			return err
+		}
+		return storeMeta(bookTx, keywordTx, path, m)
+	}
+}
+
+// backfillCovers extracts the covers of the books below root again when the
+// cover extraction of their format has changed since they were scanned. Like
+// metadata, a cover that fails is recorded and not retried until the book
+// changes.
+func backfillCovers(root string, bookDB, keywordDB *sql.DB) error {
+	var books []database.BookData
+	for _, bookType := range []string{"PDF", "EPUB", "CBZ", "CBR"} {
+		version := cover.Version(bookType)
+		if version == 0 {
+			continue
+		}
+		outdated, err := database.GetBooksWithOldCover(bookDB, bookType, version, root)
+		if err != nil {
+			return err
+		}
+		books = append(books, outdated...)
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	byPath := make(map[string]database.BookData, len(books))
+	paths := make([]string, len(books))
+	for i, book := range books {
+		byPath[book.Path] = book
+		paths[i] = book.Path
+	}
+
+	updateStatus(func(s *Status) {
+		s.Phase = PhaseBackfilling
+		s.BackfilledTotal += len(paths)
+	})
+	prepare := func(path string) (writeFunc, error) {
+		return prepareCoverBackfill(byPath[path]), nil
+	}
+	runPool(paths, prepare, bookDB, keywordDB, func(path string, err error) {
+		if err != nil {
+			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
+		}
+		updateStatus(func(s *Status) { s.Backfilled++ })
+	})
+
+	return nil
+}
+
+func prepareCoverBackfill(book database.BookData) writeFunc {
+	coverPath := book.CoverPath
+	if coverPath == "" {
+		// the cover failed before, so the book has no cover path yet
+		coverPath = coverPathFor(book.Path)
+	}
+	version := cover.Version(book.Type)
+
+	if err := extractCover(book.Path, coverPath, book.Type); err != nil {
+		fmt.Println(err)
+		coverErr := &stageError{StageCover, err}
+		return func(bookTx, keywordTx database.Querier) error {
+			if err := database.UpdateBookCover(bookTx, book.Path, book.CoverPath, version); err != nil {
+				return err
+			}
+			return database.AddScanError(bookTx, newScanError(book.Path, coverErr))
+		}
+	}
+
+	return func(bookTx, keywordTx database.Querier) error {
+		if err := database.DeleteScanError(bookTx, book.Path, StageCover); err != nil {
+			return err
+		}
+		return database.UpdateBookCover(bookTx, book.Path, coverPath, version)
+	}
+}
@@ -3,10 +3,58 @@ package scan
 import (
 	"back/database"
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 )
 
+var gracePeriod time.Duration
+
+func init() {
+	gracePeriod = time.Duration(getEnvInt("MISSING_GRACE_DAYS", 30)) * 24 * time.Hour
+}
+
+var ErrFileNotFound = errors.New("file not found")
+
+// scanDelete marks a vanished book as missing instead of deleting it, so a
+// temporarily unavailable mount does not lose any reading state. The book is
+// purged once it has been missing for longer than MISSING_GRACE_DAYS.
 func scanDelete(path string, bookDB, keywordDB *sql.DB) error {
+	return database.MarkBookMissing(bookDB, path, time.Now().Unix())
+}
+
+func scanRestore(path string, bookDB *sql.DB) error {
+	return database.RestoreBook(bookDB, path)
+}
+
+// purgeExpired deletes the books that have been missing for longer than the
+// grace period. A book that cannot be purged does not stop the others.
+func purgeExpired(bookDB, keywordDB *sql.DB) error {
+	before := time.Now().Add(-gracePeriod).Unix()
+	paths, err := database.GetMissingPathsBefore(bookDB, before)
+	if err != nil {
+		return err
+	}
+
+	return purgeBooks(paths, bookDB, keywordDB)
+}
+
+// purgeBooks purges every path, and returns the failures together.
+func purgeBooks(paths []string, bookDB, keywordDB *sql.DB) error {
+	var errs []error
+	for _, path := range paths {
+		if err := purgeBook(path, bookDB, keywordDB); err != nil {
+			err = fmt.Errorf("failed to purge %s: %w", path, err)
+			fmt.Println(err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func purgeBook(path string, bookDB, keywordDB *sql.DB) error {
 	book, err := database.GetBookByPath(bookDB, path)
 
 	if err != nil {
@@ -34,3 +82,64 @@ func deleteCoverFile(coverPath string) error {
 	}
 	return os.Remove(coverPath)
 }
+
+// PurgeMissing deletes a missing book right away, or every missing book when
+// path is empty.
+func PurgeMissing(path string, bookDB, keywordDB *sql.DB) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if path != "" {
+		book, err := database.GetBookByPath(bookDB, path)
+		if err != nil {
+			return err
+		}
+		if book.MissingSince == 0 {
+			return errors.New("book is not missing")
+		}
+		return purgeBook(path, bookDB, keywordDB)
+	}
+
+	books, err := database.GetMissingBooks(bookDB)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, len(books))
+	for i, book := range books {
+		paths[i] = book.Path
+	}
+
+	return purgeBooks(paths, bookDB, keywordDB)
+}
+
+// RestoreMissing brings a missing book back. Without a target, the file must
+// be back at its path. With a target, the reading state of the missing book is
+// moved to the book at target, e.g. a re-downloaded copy that was added as a
+// new book, and the missing book is purged.
+func RestoreMissing(path, target string, bookDB, keywordDB *sql.DB) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	book, err := database.GetBookByPath(bookDB, path)
+	if err != nil {
+		return err
+	}
+	if book.MissingSince == 0 {
+		return errors.New("book is not missing")
+	}
+
+	if target == "" {
+		if _, err := os.Stat(path); err != nil {
+			return ErrFileNotFound
+		}
+		return scanRestore(path, bookDB)
+	}
+
+	if _, err := database.GetBookByPath(bookDB, target); err != nil {
+		return err
+	}
+	if err := database.TransferReadingState(bookDB, path, target); err != nil {
+		return err
+	}
+	return purgeBook(path, bookDB, keywordDB)
+}
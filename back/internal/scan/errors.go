@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"back/database"
+	"database/sql"
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	StageCover       = "cover"
+	StageMeta        = "meta"
+	StageFingerprint = "fingerprint"
+	StageDB          = "db"
+)
+
+// stageError records which step of processing a book failed, so the failure
+// can be stored in the scan error log.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return e.stage + ": " + e.err.Error()
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+func newScanError(path string, err error) database.ScanError {
+	stage := StageDB
+	message := err.Error()
+	var se *stageError
+	if errors.As(err, &se) {
+		stage = se.stage
+		message = se.err.Error()
+	}
+
+	stderr := ""
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		stderr = string(exitErr.Stderr)
+	}
+
+	return database.ScanError{
+		Path:    path,
+		Stage:   stage,
+		Message: message,
+		Stderr:  stderr,
+		Time:    time.Now().Unix(),
+	}
+}
+
+// pruneScanErrors drops errors of files that no longer exist, since those
+// are never scanned again.
+func pruneScanErrors(bookDB *sql.DB) error {
+	scanErrs, err := database.GetScanErrors(bookDB)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range scanErrs {
+		if _, err := os.Stat(e.Path); errors.Is(err, os.ErrNotExist) {
+			if err := database.DeleteScanErrorsByPath(bookDB, e.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package scan
+
+import (
+	"back/internal/calibre"
+	"back/internal/cover"
+	"back/internal/diff"
+	"back/internal/meta"
+	"back/internal/sidecar"
+	"fmt"
+)
+
+// extractMeta reads the metadata of a book, preferring what Calibre has
+// curated over what is stored in the file itself. A sidecar file next to the
+// book overrides both.
+func extractMeta(path, bookType string) (meta.Meta, error) {
+	m, err := readMeta(path, bookType)
+	if err != nil {
+		return meta.Meta{}, err
+	}
+
+	m, err = sidecar.Apply(m, path)
+	if err != nil {
+		// a typo in the sidecar must not keep the book out of the library
+		fmt.Println(err)
+		addStatusError(err)
+	}
+
+	// must match the modification time the diff sees
+	m.ModTime = diff.ModTime(path, m.ModTime)
+	return m, nil
+}
+
+func readMeta(path, bookType string) (meta.Meta, error) {
+	if _, ok := calibre.LibraryRoot(path); ok {
+		m, err := calibre.Read(path)
+		if err == nil {
+			return m, nil
+		}
+		fmt.Println(err)
+	}
+
+	return meta.ExtractMeta(path, bookType)
+}
+
+// extractCover stores the cover of a book, using the cover.jpg of a Calibre
+// library when there is one.
+func extractCover(path, coverPath, bookType string) error {
+	if img := calibre.Cover(path); img != "" {
+		return cover.FromImage(img, coverPath)
+	}
+	return cover.ExtractCover(path, coverPath, bookType)
+}
@@ -0,0 +1,54 @@
+package scan
+
+import (
+	"back/database"
+	"back/internal/duplicate"
+	"back/internal/fingerprint"
+	"database/sql"
+	"fmt"
+)
+
+// hashDuplicates hashes the full content of the books that may be exact
+// duplicates of each other, so that the duplicate report does not have to
+// read whole files itself.
+func hashDuplicates(bookDB, keywordDB *sql.DB) error {
+	paths, err := duplicate.Unhashed(bookDB)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	updateStatus(func(s *Status) {
+		s.Phase = PhaseHashing
+		s.HashedTotal += len(paths)
+	})
+	prepare := func(path string) (writeFunc, error) {
+		return prepareHash(path), nil
+	}
+	runPool(paths, prepare, bookDB, keywordDB, func(path string, err error) {
+		if err != nil {
+			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
+		}
+		updateStatus(func(s *Status) { s.Hashed++ })
+	})
+
+	return nil
+}
+
+func prepareHash(path string) writeFunc {
+	hash, err := fingerprint.ContentHash(path)
+	if err != nil {
+		fmt.Println(err)
+		hashErr := &stageError{StageFingerprint, err}
+		return func(bookTx, keywordTx database.Querier) error {
+			return database.AddScanError(bookTx, newScanError(path, hashErr))
+		}
+	}
+
+	return func(bookTx, keywordTx database.Querier) error {
+		return database.UpdateBookContentHash(bookTx, path, hash)
+	}
+}
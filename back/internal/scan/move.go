@@ -0,0 +1,55 @@
+package scan
+
+import (
+	"back/database"
+	"back/internal/diff"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// scanMove re-points a moved or renamed book to its new path. The cover is
+// moved along so that a new book at the old path cannot overwrite it.
+func scanMove(from, to string, bookDB, keywordDB *sql.DB) error {
+	book, err := database.GetBookByPath(bookDB, from)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(to)
+	if err != nil {
+		return err
+	}
+
+	coverPath := book.CoverPath
+	if coverPath != "" {
+		newCoverPath := coverPathFor(to)
+		if err := moveCoverFile(coverPath, newCoverPath); err != nil {
+			fmt.Println(err)
+		} else {
+			coverPath = newCoverPath
+		}
+	}
+
+	// must match the modification time the diff sees, which includes the
+	// sidecar and Calibre files of the book
+	err = database.MoveBook(bookDB, from, to, coverPath, diff.ModTime(to, info.ModTime().Unix()))
+	if err != nil {
+		return err
+	}
+
+	err = database.MoveKeywords(keywordDB, from, to)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func moveCoverFile(oldPath, newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create cover dir: %w", err)
+	}
+	return os.Rename(oldPath, newPath)
+}
@@ -0,0 +1,152 @@
+package scan
+
+import (
+	"back/database"
+	"back/internal/meta"
+	"database/sql"
+	"encoding/json"
+)
+
+// storeMeta writes the metadata of a book with the user's edits applied. The
+// metadata as extracted is kept as well, so that edits can be changed or
+// reset later without extracting the book again.
+func storeMeta(bookTx, keywordTx database.Querier, path string, m meta.Meta) error {
+	scanned, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := database.SetScannedMeta(bookTx, path, string(scanned)); err != nil {
+		return err
+	}
+
+	o, err := database.GetOverride(bookTx, path)
+	if err != nil {
+		return err
+	}
+	m = applyOverride(m, o)
+
+	if err := database.UpdateBookTitle(bookTx, path, m.Title); err != nil {
+		return err
+	}
+	if err := database.UpdateBookDetails(bookTx, withMeta(database.BookData{Path: path}, m)); err != nil {
+		return err
+	}
+	return replaceKeywords(keywordTx, path, m.Keywords)
+}
+
+func applyOverride(m meta.Meta, o database.Override) meta.Meta {
+	if o.Title != nil {
+		m.Title = *o.Title
+	}
+
+	if o.Authors != nil {
+		// only the authors are replaced, illustrators and the like are kept
+		replaced := make(map[string]bool)
+		var contributors []meta.Contributor
+		for _, name := range *o.Authors {
+			contributors = append(contributors, meta.NewContributor(name, meta.RoleAuthor))
+		}
+		for _, c := range m.Contributors {
+			if c.Role == meta.RoleAuthor || c.Role == meta.RoleWriter {
+				replaced[c.Name] = true
+			} else {
+				contributors = append(contributors, c)
+			}
+		}
+		m.Contributors = contributors
+
+		m.Keywords = without(m.Keywords, replaced)
+		for _, name := range *o.Authors {
+			m.Keywords = append(m.Keywords, name)
+		}
+	}
+
+	if o.Series != nil {
+		m.Keywords = without(m.Keywords, map[string]bool{m.Series: true})
+		m.Series = *o.Series
+		m.SeriesNumber = *o.SeriesNumber
+		if m.Series != "" {
+			m.Keywords = append(m.Keywords, m.Series)
+		}
+	}
+
+	// the tags replace every keyword that is not a name, series or publisher
+	if o.Tags != nil {
+		var keywords []string
+		for _, c := range m.Contributors {
+			keywords = append(keywords, c.Name)
+		}
+		if m.Series != "" {
+			keywords = append(keywords, m.Series)
+		}
+		if m.Publisher != "" {
+			keywords = append(keywords, m.Publisher)
+		}
+		m.Keywords = append(keywords, *o.Tags...)
+	}
+
+	return m
+}
+
+func without(keywords []string, drop map[string]bool) []string {
+	var result []string
+	for _, k := range keywords {
+		if !drop[k] {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// SetOverride stores the user's edits of a book and applies them at once.
+// Fields left nil in o are reset to the scanned value.
+func SetOverride(path string, o database.Override, bookDB, keywordDB *sql.DB) error {
+	// a scan must not replace the scanned metadata while the edits are
+	// applied to it
+	mu.Lock()
+	defer mu.Unlock()
+
+	bookTx, err := bookDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer bookTx.Rollback()
+
+	keywordTx, err := keywordDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer keywordTx.Rollback()
+
+	book, err := database.GetBookByPath(bookTx, path)
+	if err != nil {
+		return err
+	}
+
+	scanned, err := database.GetScannedMeta(bookTx, path)
+	if err != nil {
+		return err
+	}
+
+	var m meta.Meta
+	if scanned == "" {
+		// stored by a version that did not keep the scanned metadata
+		if m, err = extractMeta(path, book.Type); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal([]byte(scanned), &m); err != nil {
+		return err
+	}
+
+	if err := database.SetOverride(bookTx, path, o); err != nil {
+		return err
+	}
+	if err := storeMeta(bookTx, keywordTx, path, m); err != nil {
+		return err
+	}
+
+	if err := bookTx.Commit(); err != nil {
+		return err
+	}
+	return keywordTx.Commit()
+}
@@ -0,0 +1,208 @@
+package scan
+
+import (
+	"back/database"
+	"database/sql"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	batchSize     = 100
+	flushInterval = 5 * time.Second
+)
+
+var workers int
+
+func init() {
+	workers = getEnvInt("SCAN_WORKERS", runtime.NumCPU())
+	if workers < 1 {
+		workers = 1
+	}
+}
+
+func getEnvInt(key string, def int) int {
+	if val := os.Getenv(key); val != "" {
+		if v, err := strconv.Atoi(val); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+// writeFunc stores the result of an extraction. It runs inside the batch
+// transactions of the book and keyword databases, and clears the scan errors
+// of the stages it ran again.
+type writeFunc func(bookTx, keywordTx database.Querier) error
+
+type prepared struct {
+	path  string
+	write writeFunc
+	err   error
+}
+
+// runPool runs prepare for every path on SCAN_WORKERS goroutines, since cover
+// and metadata extraction spend most of their time in gs, 7z and pdfinfo.
+// The results are written by a single goroutine in batched transactions, and
+// done is called once per path after its result has been committed.
+func runPool(
+	paths []string,
+	prepare func(path string) (writeFunc, error),
+	bookDB, keywordDB *sql.DB,
+	done func(path string, err error),
+) {
+	jobs := make(chan string)
+	results := make(chan prepared)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				updateStatus(func(s *Status) { s.CurrentFile = path })
+				write, err := prepare(path)
+				results <- prepared{path: path, write: write, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, path := range paths {
+			jobs <- path
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var pending []prepared
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				writeBatch(pending, bookDB, keywordDB, done)
+				return
+			}
+			pending = append(pending, r)
+			if len(pending) >= batchSize {
+				writeBatch(pending, bookDB, keywordDB, done)
+				pending = nil
+			}
+		case <-ticker.C:
+			writeBatch(pending, bookDB, keywordDB, done)
+			pending = nil
+		}
+	}
+}
+
+// writeBatch commits a batch of results in one transaction per database. Each
+// book is wrapped in a savepoint so a failing book does not leave half of its
+// rows behind or abort the rest of the batch.
+func writeBatch(batch []prepared, bookDB, keywordDB *sql.DB, done func(path string, err error)) {
+	if len(batch) == 0 {
+		return
+	}
+
+	bookTx, err := bookDB.Begin()
+	if err != nil {
+		failBatch(batch, err, done)
+		return
+	}
+	defer bookTx.Rollback()
+
+	keywordTx, err := keywordDB.Begin()
+	if err != nil {
+		failBatch(batch, err, done)
+		return
+	}
+	defer keywordTx.Rollback()
+
+	errs := make([]error, len(batch))
+	for i, r := range batch {
+		if r.err != nil {
+			// the book could not be read at all, so this replaces whatever
+			// earlier scans recorded for it
+			if err := database.DeleteScanErrorsByPath(bookTx, r.path); err != nil {
+				fmt.Println(err)
+			}
+			errs[i] = r.err
+		} else {
+			errs[i] = writeOne(r.write, bookTx, keywordTx)
+		}
+
+		if errs[i] != nil {
+			if err := database.AddScanError(bookTx, newScanError(r.path, errs[i])); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+
+	// Keywords are committed first: if that fails the books are rolled back
+	// with their old modification time, so the next scan writes both again.
+	// Keyword writes replace all of a book's keywords and can be repeated.
+	if err := keywordTx.Commit(); err != nil {
+		failBatch(batch, err, done)
+		return
+	}
+	if err := bookTx.Commit(); err != nil {
+		failBatch(batch, err, done)
+		return
+	}
+
+	for i, r := range batch {
+		done(r.path, errs[i])
+	}
+}
+
+func writeOne(write writeFunc, bookTx, keywordTx *sql.Tx) error {
+	if _, err := bookTx.Exec(`SAVEPOINT book`); err != nil {
+		return err
+	}
+	if _, err := keywordTx.Exec(`SAVEPOINT book`); err != nil {
+		rollbackSavepoint(bookTx)
+		return err
+	}
+
+	if err := write(bookTx, keywordTx); err != nil {
+		rollbackSavepoint(bookTx)
+		rollbackSavepoint(keywordTx)
+		return err
+	}
+
+	if _, err := keywordTx.Exec(`RELEASE book`); err != nil {
+		rollbackSavepoint(bookTx)
+		rollbackSavepoint(keywordTx)
+		return err
+	}
+	if _, err := bookTx.Exec(`RELEASE book`); err != nil {
+		rollbackSavepoint(bookTx)
+		return err
+	}
+
+	return nil
+}
+
+// rollbackSavepoint undoes and closes the savepoint of a book, so that the
+// rest of the batch is not written inside it.
+func rollbackSavepoint(tx *sql.Tx) {
+	tx.Exec(`ROLLBACK TO book`)
+	tx.Exec(`RELEASE book`)
+}
+
+func failBatch(batch []prepared, err error, done func(path string, err error)) {
+	for _, r := range batch {
+		if r.err != nil {
+			done(r.path, r.err)
+		} else {
+			done(r.path, fmt.Errorf("failed to write batch: %w", err))
+		}
+	}
+}
@@ -1,37 +1,245 @@
 package scan
 
 import (
+	"back/database"
 	"back/internal/diff"
+	"back/internal/library"
 	"database/sql"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 )
 
+// mu serializes scans so the startup scan, the watcher and on-demand scans
+// never process the same book twice.
+var mu sync.Mutex
+
+var (
+	queueMu sync.Mutex
+	queued  = make(map[string]bool)
+)
+
+// Scan synchronizes every library with the database.
 func Scan(bookDB, keywordDB *sql.DB) error {
-	diffResult, err := diff.Diff(bookDB)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return scan("", bookDB, keywordDB)
+}
+
+// Trigger queues a background scan of root, or of every library when root is
+// empty. It returns false when a queued scan that has not started yet already
+// covers root, in which case the request is merged into it.
+func Trigger(root string, bookDB, keywordDB *sql.DB) bool {
+	queueMu.Lock()
+	for q := range queued {
+		if isWithin(root, q) {
+			queueMu.Unlock()
+			return false
+		}
+	}
+	queued[root] = true
+	queueMu.Unlock()
+
+	go func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// from here on, changes are no longer guaranteed to be seen by this
+		// scan, so later requests must queue a new one
+		queueMu.Lock()
+		delete(queued, root)
+		queueMu.Unlock()
+
+		if err := scan(root, bookDB, keywordDB); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	return true
+}
+
+func scan(root string, bookDB, keywordDB *sql.DB) error {
+	startStatus()
+	defer finishStatus()
+
+	// before the diff, which recognises moved books by their fingerprint
+	if err := backfillFingerprints(root, bookDB, keywordDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+
+	updateStatus(func(s *Status) { s.Phase = PhaseListing })
+	roots := []string{root}
+	if root == "" {
+		roots = availableRoots()
+	}
+
+	diffResult, err := diff.Diff(bookDB, roots)
+	if err != nil {
+		addStatusError(err)
+		return err
+	}
+
+	if root == "" {
+		orphans, err := diff.Orphans(bookDB)
+		if err != nil {
+			addStatusError(err)
+			return err
+		}
+		diffResult.Deleted = append(diffResult.Deleted, orphans...)
+	}
+
+	apply(diffResult, bookDB, keywordDB)
+
+	// after the diff, so that changed books are only extracted once
+	if err := backfillMeta(root, bookDB, keywordDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+	if err := backfillCovers(root, bookDB, keywordDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+	if err := hashDuplicates(bookDB, keywordDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+
+	// books that cannot be purged are tried again by the next scan, and must
+	// not keep the clean-up below from running
+	if err := purgeExpired(bookDB, keywordDB); err != nil {
+		addStatusError(err)
+	}
+
+	if err := pruneScanErrors(bookDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+
+	if err := database.DeleteEmptySeries(bookDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+	if err := database.DeleteEmptyAuthors(bookDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+
+	return nil
+}
+
+// availableRoots returns the library roots that can currently be read. A
+// library whose mount is missing is skipped instead of having all of its
+// books deleted.
+func availableRoots() []string {
+	var roots []string
+	for _, root := range library.Roots() {
+		if _, err := os.Stat(root); err != nil {
+			addStatusError(fmt.Errorf("skipping library root: %w", err))
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+func isWithin(path, dir string) bool {
+	dir = strings.TrimSuffix(dir, "/")
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// ScanPaths rescans only the given files or directories.
+func ScanPaths(paths []string, bookDB, keywordDB *sql.DB) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	startStatus()
+	defer finishStatus()
+
+	diffResult, err := diff.DiffPaths(bookDB, paths)
 	if err != nil {
+		addStatusError(err)
 		return err
 	}
 
-	for _, path := range diffResult.Added {
-		err := scanAdd(path, bookDB, keywordDB)
+	apply(diffResult, bookDB, keywordDB)
+
+	if err := hashDuplicates(bookDB, keywordDB); err != nil {
+		addStatusError(err)
+		return err
+	}
+
+	return nil
+}
+
+func apply(diffResult diff.DiffResult, bookDB, keywordDB *sql.DB) {
+	updateStatus(func(s *Status) {
+		s.AddedTotal = len(diffResult.Added)
+		s.UpdatedTotal = len(diffResult.Updated)
+		s.DeletedTotal = len(diffResult.Deleted)
+		s.MovedTotal = len(diffResult.Moved)
+		s.RestoredTotal = len(diffResult.Restored)
+	})
+
+	for _, path := range diffResult.Restored {
+		updateStatus(func(s *Status) {
+			s.Phase = PhaseRestoring
+			s.CurrentFile = path
+		})
+		err := scanRestore(path, bookDB)
 		if err != nil {
 			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
 		}
+		updateStatus(func(s *Status) { s.Restored++ })
 	}
 
-	for _, path := range diffResult.Updated {
-		err := scanUpdate(path, bookDB, keywordDB)
+	for _, move := range diffResult.Moved {
+		updateStatus(func(s *Status) {
+			s.Phase = PhaseMoving
+			s.CurrentFile = move.To
+		})
+		err := scanMove(move.From, move.To, bookDB, keywordDB)
 		if err != nil {
 			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", move.To, err))
 		}
+		updateStatus(func(s *Status) { s.Moved++ })
 	}
 
+	updateStatus(func(s *Status) { s.Phase = PhaseAdding })
+	runPool(diffResult.Added, prepareAdd, bookDB, keywordDB, func(path string, err error) {
+		if err != nil {
+			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
+		}
+		updateStatus(func(s *Status) { s.Added++ })
+	})
+
+	updateStatus(func(s *Status) { s.Phase = PhaseUpdating })
+	prepare := func(path string) (writeFunc, error) {
+		return prepareUpdate(path, bookDB)
+	}
+	runPool(diffResult.Updated, prepare, bookDB, keywordDB, func(path string, err error) {
+		if err != nil {
+			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
+		}
+		updateStatus(func(s *Status) { s.Updated++ })
+	})
+
 	for _, path := range diffResult.Deleted {
+		updateStatus(func(s *Status) {
+			s.Phase = PhaseDeleting
+			s.CurrentFile = path
+		})
 		err := scanDelete(path, bookDB, keywordDB)
 		if err != nil {
 			fmt.Println(err)
+			addStatusError(fmt.Errorf("%s: %w", path, err))
 		}
+		updateStatus(func(s *Status) { s.Deleted++ })
 	}
-
-	return nil
 }
@@ -0,0 +1,90 @@
+package scan
+
+import (
+	"sync"
+	"time"
+)
+
+const maxStatusErrors = 100
+
+type Status struct {
+	Phase           string   `json:"phase"`
+	Added           int      `json:"added"`
+	AddedTotal      int      `json:"addedTotal"`
+	Updated         int      `json:"updated"`
+	UpdatedTotal    int      `json:"updatedTotal"`
+	Deleted         int      `json:"deleted"`
+	DeletedTotal    int      `json:"deletedTotal"`
+	Moved           int      `json:"moved"`
+	MovedTotal      int      `json:"movedTotal"`
+	Restored        int      `json:"restored"`
+	RestoredTotal   int      `json:"restoredTotal"`
+	Backfilled      int      `json:"backfilled"`
+	BackfilledTotal int      `json:"backfilledTotal"`
+	Hashed          int      `json:"hashed"`
+	HashedTotal     int      `json:"hashedTotal"`
+	CurrentFile     string   `json:"currentFile"`
+	Errors          []string `json:"errors"`
+	StartedTime     int64    `json:"startedTime"`
+	FinishedTime    int64    `json:"finishedTime"`
+}
+
+const (
+	PhaseIdle        = "idle"
+	PhaseListing     = "listing"
+	PhaseMoving      = "moving"
+	PhaseRestoring   = "restoring"
+	PhaseAdding      = "adding"
+	PhaseUpdating    = "updating"
+	PhaseDeleting    = "deleting"
+	PhaseBackfilling = "backfilling"
+	PhaseHashing     = "hashing"
+)
+
+var (
+	statusMu sync.Mutex
+	status   = Status{Phase: PhaseIdle, Errors: []string{}}
+)
+
+// GetStatus returns a snapshot of the running scan, or of the last finished
+// one when the scanner is idle.
+func GetStatus() Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	s := status
+	s.Errors = append([]string{}, status.Errors...)
+	return s
+}
+
+func updateStatus(f func(s *Status)) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	f(&status)
+}
+
+func startStatus() {
+	updateStatus(func(s *Status) {
+		*s = Status{
+			Phase:       PhaseListing,
+			Errors:      []string{},
+			StartedTime: time.Now().Unix(),
+		}
+	})
+}
+
+func finishStatus() {
+	updateStatus(func(s *Status) {
+		s.Phase = PhaseIdle
+		s.CurrentFile = ""
+		s.FinishedTime = time.Now().Unix()
+	})
+}
+
+func addStatusError(err error) {
+	updateStatus(func(s *Status) {
+		if len(s.Errors) < maxStatusErrors {
+			s.Errors = append(s.Errors, err.Error())
+		}
+	})
+}
@@ -3,37 +3,81 @@ package scan
 import (
 	"back/database"
 	"back/internal/cover"
-	"back/internal/meta"
+	"back/internal/fingerprint"
+	"back/internal/pageindex"
 	"database/sql"
 	"fmt"
 )
 
-func scanUpdate(path string, bookDB, keywordDB *sql.DB) error {
+// prepareUpdate re-extracts the cover and metadata of a changed book. The
+// database is only written by the returned writeFunc.
+func prepareUpdate(path string, bookDB *sql.DB) (writeFunc, error) {
 	book, err := database.GetBookByPath(bookDB, path)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = cover.ExtractCover(path, book.CoverPath, book.Type)
+	var coverErr error
+	err = extractCover(path, book.CoverPath, book.Type)
 	if err != nil {
 		fmt.Println(err)
+		coverErr = &stageError{StageCover, err}
 	}
 
-	title, keywords, last_modded, err := meta.ExtractMeta(path, book.Type)
+	m, err := extractMeta(path, book.Type)
 	if err != nil {
-		return err
+		return nil, &stageError{StageMeta, err}
 	}
 
-	database.UpdateBookTitleAndModTime(bookDB, path, title, last_modded)
+	size, fp, err := fingerprint.Compute(path)
+	if err != nil {
+		return nil, &stageError{StageFingerprint, err}
+	}
+
+	return func(bookTx, keywordTx database.Querier) error {
+		// every stage ran again, so errors from earlier scans are replaced by
+		// the outcome of this one
+		if err := database.DeleteScanErrorsByPath(bookTx, path); err != nil {
+			return err
+		}
+		if err := database.UpdateBookTitleAndModTime(bookTx, path, m.Title, m.ModTime); err != nil {
+			return err
+		}
+		if err := database.UpdateBookFingerprint(bookTx, path, size, fp); err != nil {
+			return err
+		}
+		if err := database.UpdateBookCover(bookTx, path, book.CoverPath, cover.Version(book.Type)); err != nil {
+			return err
+		}
+		// the pages may have changed along with the file
+		if err := database.DeletePageIndex(bookTx, path); err != nil {
+			return err
+		}
+		pageindex.Invalidate(path)
+		if err := database.DeletePDFTOC(bookTx, path); err != nil {
+			return err
+		}
+		if err := storeMeta(bookTx, keywordTx, path, m); err != nil {
+			return err
+		}
+
+		if coverErr != nil {
+			return database.AddScanError(bookTx, newScanError(path, coverErr))
+		}
+
+		return nil
+	}, nil
+}
 
-	database.DeleteKeywordsByPath(keywordDB, path)
+func replaceKeywords(keywordDB database.Execer, path string, keywords []string) error {
+	if err := database.DeleteKeywordsByPath(keywordDB, path); err != nil {
+		return err
+	}
 	for _, keyword := range keywords {
-		err = database.AddKeyword(keywordDB, path, keyword)
-		if err != nil {
+		if err := database.AddKeyword(keywordDB, path, keyword); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
@@ -0,0 +1,178 @@
+package sidecar
+
+import (
+	"back/internal/meta"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// extensions are appended to the book file name, e.g. book.pdf.opf. The
+// first one found is used.
+var extensions = []string{".opf", ".json"}
+
+// file is the JSON sidecar format. Every field is optional and only the
+// fields present override the metadata of the book.
+type file struct {
+	Title        string            `json:"title"`
+	Authors      []string          `json:"authors"`
+	Series       string            `json:"series"`
+	SeriesNumber json.Number       `json:"seriesNumber"`
+	Publisher    string            `json:"publisher"`
+	Language     string            `json:"language"`
+	Direction    string            `json:"direction"`
+	Description  string            `json:"description"`
+	Published    string            `json:"published"`
+	Pages        int               `json:"pages"`
+	Rating       float64           `json:"rating"`
+	Identifiers  map[string]string `json:"identifiers"`
+	Tags         []string          `json:"tags"`
+}
+
+// BookFor returns the book a sidecar file belongs to.
+func BookFor(path string) (string, bool) {
+	for _, ext := range extensions {
+		if book, ok := strings.CutSuffix(path, ext); ok {
+			if info, err := os.Stat(book); err == nil && !info.IsDir() {
+				return book, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ModTime returns the later of modTime and the modification time of the
+// sidecar of the book, so that editing the sidecar rescans the book.
+func ModTime(bookPath string, modTime int64) int64 {
+	for _, ext := range extensions {
+		if info, err := os.Stat(bookPath + ext); err == nil {
+			return max(modTime, info.ModTime().Unix())
+		}
+	}
+	return modTime
+}
+
+// Apply overrides m with the fields set in the sidecar of the book. m is
+// returned unchanged when the book has no sidecar, and along with the error
+// when the sidecar cannot be read or parsed.
+func Apply(m meta.Meta, bookPath string) (meta.Meta, error) {
+	for _, ext := range extensions {
+		data, err := os.ReadFile(bookPath + ext)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return m, fmt.Errorf("failed to read sidecar: %w", err)
+		}
+
+		var override meta.Meta
+		if ext == ".opf" {
+			override, err = meta.ParseOPF(data)
+		} else {
+			override, err = parseJSON(data)
+		}
+		if err != nil {
+			return m, fmt.Errorf("failed to parse %s: %w", bookPath+ext, err)
+		}
+
+		return meta.Normalise(merge(m, override), bookPath), nil
+	}
+
+	return m, nil
+}
+
+func parseJSON(data []byte) (meta.Meta, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return meta.Meta{}, err
+	}
+
+	m := meta.Meta{
+		Title:        f.Title,
+		Series:       f.Series,
+		SeriesNumber: f.SeriesNumber.String(),
+		Publisher:    f.Publisher,
+		Language:     f.Language,
+		Direction:    f.Direction,
+		Description:  f.Description,
+		Published:    f.Published,
+		Pages:        f.Pages,
+		Rating:       f.Rating,
+		Keywords:     f.Tags,
+	}
+	for _, author := range f.Authors {
+		m.Contributors = append(m.Contributors, meta.Contributor{Name: author, Role: meta.RoleAuthor})
+		m.Keywords = append(m.Keywords, author)
+	}
+	for scheme, value := range f.Identifiers {
+		m.Identifiers = append(m.Identifiers, meta.Identifier{Scheme: strings.ToLower(scheme), Value: value})
+	}
+	if m.Series != "" {
+		m.Keywords = append(m.Keywords, m.Series)
+	}
+	if m.Publisher != "" {
+		m.Keywords = append(m.Keywords, m.Publisher)
+	}
+
+	return m, nil
+}
+
+// merge lets every field set in override replace the one in m. Keywords are
+// added, except that the replaced contributors, series and publisher are
+// dropped.
+func merge(m, override meta.Meta) meta.Meta {
+	if override.Title != "" {
+		m.Title = override.Title
+	}
+
+	replaced := make(map[string]bool)
+	if len(override.Contributors) > 0 {
+		for _, c := range m.Contributors {
+			replaced[c.Name] = true
+		}
+		m.Contributors = override.Contributors
+	}
+	if override.Series != "" {
+		replaced[m.Series] = true
+		m.Series = override.Series
+		m.SeriesNumber = override.SeriesNumber
+	}
+	if override.Publisher != "" {
+		replaced[m.Publisher] = true
+		m.Publisher = override.Publisher
+	}
+
+	var keywords []string
+	for _, k := range m.Keywords {
+		if !replaced[k] {
+			keywords = append(keywords, k)
+		}
+	}
+	m.Keywords = append(keywords, override.Keywords...)
+
+	if override.Language != "" {
+		m.Language = override.Language
+	}
+	if override.Direction != "" {
+		m.Direction = override.Direction
+	}
+	if len(override.Identifiers) > 0 {
+		m.Identifiers = override.Identifiers
+	}
+	if override.Description != "" {
+		m.Description = override.Description
+	}
+	if override.Published != "" {
+		m.Published = override.Published
+	}
+	if override.Pages != 0 {
+		m.Pages = override.Pages
+	}
+	if override.Rating != 0 {
+		m.Rating = override.Rating
+	}
+
+	return m
+}
@@ -0,0 +1,156 @@
+package watch
+
+import (
+	"back/internal/ignore"
+	"back/internal/library"
+	"back/internal/scan"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	enabled      bool
+	delay        time.Duration
+	pollInterval time.Duration
+)
+
+func init() {
+	enabled = getEnvBool("WATCH", true)
+	delay = time.Duration(getEnvInt("WATCH_DELAY", 5)) * time.Second
+	pollInterval = time.Duration(getEnvInt("POLL_INTERVAL", 600)) * time.Second
+}
+
+func getEnvInt(key string, def int) int {
+	if val := os.Getenv(key); val != "" {
+		if v, err := strconv.Atoi(val); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+func getEnvBool(key string, def bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if v, err := strconv.ParseBool(val); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+// Watch keeps the database in sync with the libraries while the server is running.
+// Paths reported by inotify are rescanned once they have been quiet for
+// WATCH_DELAY seconds, and a full diff runs every POLL_INTERVAL seconds to
+// catch changes on network mounts that never deliver inotify events.
+func Watch(bookDB, keywordDB *sql.DB) {
+	var watcher *fsnotify.Watcher
+	var events chan fsnotify.Event
+	var errs chan error
+
+	if enabled {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Printf("failed to start file watcher, falling back to polling: %v", err)
+		} else {
+			defer w.Close()
+			for _, root := range library.Roots() {
+				if err := addRecursive(w, root); err != nil {
+					log.Printf("failed to watch %s: %v", root, err)
+				}
+			}
+			watcher = w
+			events = w.Events
+			errs = w.Errors
+		}
+	}
+
+	var poll <-chan time.Time
+	if pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	if events == nil && poll == nil {
+		return
+	}
+
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(delay)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addRecursive(watcher, event.Name); err != nil {
+						log.Printf("failed to watch %s: %v", event.Name, err)
+					}
+				}
+			}
+			pending[event.Name] = struct{}{}
+			timer.Reset(delay)
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Printf("file watcher error: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// events were dropped, so only a full diff can catch up
+				if err := scan.Scan(bookDB, keywordDB); err != nil {
+					log.Printf("scan failed: %v", err)
+				}
+			}
+
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			pending = make(map[string]struct{})
+
+			if err := scan.ScanPaths(paths, bookDB, keywordDB); err != nil {
+				log.Printf("scan failed: %v", err)
+			}
+
+		case <-poll:
+			if err := scan.Scan(bookDB, keywordDB); err != nil {
+				log.Printf("scan failed: %v", err)
+			}
+		}
+	}
+}
+
+// addRecursive watches dir and every directory below it that is not ignored,
+// since inotify watches are not recursive.
+func addRecursive(w *fsnotify.Watcher, dir string) error {
+	if ignore.Ignored(dir, true) {
+		return nil
+	}
+	if err := w.Add(dir); err != nil {
+		return err
+	}
+
+	return ignore.Walk(dir, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			return w.Add(path)
+		}
+		return nil
+	})
+}
@@ -4,7 +4,12 @@ import (
 	"back/api"
 	"back/api/stream"
 	"back/database"
+	"back/internal/config"
+	"back/internal/ignore"
+	"back/internal/library"
 	"back/internal/scan"
+	"back/internal/watch"
+	"log"
 	"os"
 	"strconv"
 
@@ -19,6 +24,17 @@ func main() {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 
+	// config
+
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	if err := library.Init(cfg.Libraries); err != nil {
+		panic(err)
+	}
+	ignore.Init(cfg.Ignore, cfg.IncludeHidden, cfg.FollowSymlinks)
+
 	// db
 
 	bookDB := database.OpenBookDB()
@@ -29,13 +45,20 @@ func main() {
 
 	// scan books
 
-	if err := scan.Scan(bookDB, keywordDB); err != nil {
-		panic(err)
-	}
+	go func() {
+		if err := scan.Scan(bookDB, keywordDB); err != nil {
+			log.Printf("initial scan failed: %v", err)
+			return
+		}
+		println("📚 Book scanning completed.")
+	}()
+
+	go watch.Watch(bookDB, keywordDB)
+	go stream.PrerenderIdle(bookDB)
 
 	// api
 
-	println("📚 Book scanning completed. Starting the server now...")
+	println("📚 Book scanning started in the background. Starting the server now...")
 
 	pageSize := 20
 	if v := os.Getenv("PAGE_SIZE"); v != "" {
@@ -44,20 +67,37 @@ func main() {
 		}
 	}
 
+	r.GET("/api/libraries", api.LibrariesHandler())
+	r.GET("/api/book", api.BookHandler(bookDB))
+	r.PUT("/api/book/metadata", api.MetadataHandler(bookDB, keywordDB))
 	r.GET("/api/all", api.AllHandler(bookDB, pageSize))
 	r.GET("/api/root/*path", api.RootHandler(bookDB, pageSize))
 	r.GET("/api/search", api.SearchHandler(bookDB, keywordDB, pageSize))
+	r.GET("/api/series", api.SeriesHandler(bookDB, pageSize))
+	r.GET("/api/series/:id", api.SeriesBooksHandler(bookDB))
+	r.GET("/api/authors", api.AuthorsHandler(bookDB, pageSize))
+	r.GET("/api/authors/:id/books", api.AuthorBooksHandler(bookDB, pageSize))
 	r.GET("/api/progress", api.ProgressHandler(bookDB))
 	r.GET("/api/access", api.AccessHandler(bookDB))
+	r.POST("/api/scan", api.ScanHandler(bookDB, keywordDB))
+	r.GET("/api/scan/status", api.ScanStatusHandler())
+	r.GET("/api/render/status", api.RenderStatusHandler())
+	r.GET("/api/scan/errors", api.ScanErrorsHandler(bookDB))
+	r.GET("/api/duplicates", api.DuplicatesHandler(bookDB))
+
+	r.GET("/api/admin/missing", api.MissingHandler(bookDB))
+	r.POST("/api/admin/missing/purge", api.PurgeMissingHandler(bookDB, keywordDB))
+	r.POST("/api/admin/missing/restore", api.RestoreMissingHandler(bookDB, keywordDB))
 
 	r.GET("/book/epub", stream.EPUBStreamHandler())
 
-	r.GET("/book/pdf", stream.PDFStreamHandler())
+	r.GET("/book/pdf", stream.PDFStreamHandler(bookDB))
 	r.GET("/book/pdf/pages", stream.PDFPagesHandler())
-	r.GET("/book/cbr", stream.CBRStreamHandler())
-	r.GET("/book/cbr/pages", stream.CBRPagesHandler())
-	r.GET("/book/cbz", stream.CBZStreamHandler())
-	r.GET("/book/cbz/pages", stream.CBZPagesHandler())
+	r.GET("/book/pdf/toc", stream.PDFTOCHandler(bookDB))
+	r.GET("/book/cbr", stream.CBRStreamHandler(bookDB))
+	r.GET("/book/cbr/pages", stream.CBRPagesHandler(bookDB))
+	r.GET("/book/cbz", stream.CBZStreamHandler(bookDB))
+	r.GET("/book/cbz/pages", stream.CBZPagesHandler(bookDB))
 
 	r.GET("/cover/*path", api.CoverHandler())
 